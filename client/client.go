@@ -2,38 +2,182 @@ package client
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"net"
 	"net/http"
+	neturl "net/url"
+	"time"
+
+	"github.com/zhao-kun/reminder-tgbot/logging"
+	"github.com/zhao-kun/reminder-tgbot/model"
+)
+
+const (
+	defaultTimeout     = "30s"
+	defaultDialTimeout = "10s"
 )
 
+type (
+	// Client send http request to remote server
+	Client interface {
+		// HandleRequest send a request with json `reqBody` and return the body
+		// of response, the request will be canceled when `ctx` is done
+		HandleRequest(ctx context.Context, httpMethod string, url string, reqBody []byte) ([]byte, error)
+		// HandleRequestWithContentType is same as HandleRequest except the
+		// `reqBody` is encoded as `contentType`
+		HandleRequestWithContentType(ctx context.Context, httpMethod string, url string,
+			contentType string, reqBody []byte) ([]byte, error)
+	}
+
+	client struct {
+		hc *http.Client
+		l  logging.Logger
+	}
+)
+
+var _ Client = client{}
+
 // HandleRequest send message to tg
-func HandleRequest(httpMethod string, url string, reqBody []byte) ([]byte, error) {
-	req, err := http.NewRequest(httpMethod, url, bytes.NewReader(reqBody))
+func (c client) HandleRequest(ctx context.Context, httpMethod string, url string, reqBody []byte) ([]byte, error) {
+	return c.HandleRequestWithContentType(ctx, httpMethod, url, "application/json", reqBody)
+}
+
+func (c client) HandleRequestWithContentType(ctx context.Context, httpMethod string, url string,
+	contentType string, reqBody []byte) ([]byte, error) {
+	l := c.l.With(logging.F("method", httpMethod), logging.F("url", url))
+	req, err := http.NewRequestWithContext(ctx, httpMethod, url, bytes.NewReader(reqBody))
 	if err != nil {
-		log.Printf("new request [%s] %s error %s", httpMethod, url, err)
+		err = c.redactError(err)
+		l.Error("new request failed", logging.Err(err))
 		return nil, err
 	}
 
-	req.Header.Add("Content-Type", "application/json")
-	resp, err := http.DefaultClient.Do(req)
+	req.Header.Add("Content-Type", contentType)
+	resp, err := c.hc.Do(req)
 	if err != nil {
-		log.Printf("client Do failed: %v", err)
+		err = c.redactError(err)
+		l.Error("client Do failed", logging.Err(err))
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("readAll failed: %v", err)
+		l.Error("read response body failed", logging.Err(err))
 		return nil, err
 	}
 
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		log.Printf("receive body:%s\n", body)
+		l.Debug("receive response", logging.F("body", body))
 		return body, nil
 	}
-	return body, fmt.Errorf("server return error %+v", resp)
+	// the request url may contain bot token, so it isn't a part of error
+	return body, fmt.Errorf("server return error status %s, body %s",
+		resp.Status, c.l.Redact(string(body)))
+
+}
+
+// redactError remove secrets from the url carried by `err`
+func (c client) redactError(err error) error {
+	if urlErr, ok := err.(*neturl.Error); ok {
+		urlErr.URL = c.l.Redact(urlErr.URL)
+		return urlErr
+	}
+	return fmt.Errorf("%s", c.l.Redact(err.Error()))
+}
+
+// New return a Client configured by `cfg`, which log by `l`
+func New(cfg model.HTTPClient, l logging.Logger) (Client, error) {
+	timeout, err := parseDuration(cfg.Timeout, defaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	dialTimeout, err := parseDuration(cfg.DialTimeout, defaultDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := newTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   dialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: dialTimeout,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+
+	if cfg.ProxyURL != "" {
+		proxy, err := neturl.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("proxy url %s is invalid: %s", cfg.ProxyURL, err)
+		}
+		switch proxy.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("proxy scheme %s is not supported", proxy.Scheme)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	return client{
+		hc: &http.Client{
+			Transport: transport,
+			Timeout:   timeout,
+		},
+		l: l,
+	}, nil
+}
+
+func parseDuration(value string, defaultValue string) (time.Duration, error) {
+	if value == "" {
+		value = defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("Duration:%s is not valid duration representation", value)
+	}
+	return d, nil
+}
 
+func newTLSConfig(cfg model.TLS) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca file %s error %s", cfg.CAFile, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate was found in %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate error %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
 }
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const (
+	appName        = "reminder-tgbot"
+	configFileName = "config.json"
+
+	envConfig    = "TGBOT_CONFIG"
+	envConfigDir = "TGBOT_CONFIG_DIR"
+	envDataDir   = "TGBOT_DATA_DIR"
+)
+
+// dirOptions is the location of config file and data directory given by
+// flags, empty value is resolved by environment variables and defaults
+type dirOptions struct {
+	config    string
+	configDir string
+	dataDir   string
+}
+
+// resolveConfigPath return path of the config file, it's looked up in order:
+// --config, TGBOT_CONFIG, --config-dir, TGBOT_CONFIG_DIR,
+// $XDG_CONFIG_HOME/reminder-tgbot, /etc/reminder-tgbot and the directory of
+// binary
+func resolveConfigPath(opts dirOptions) string {
+	if path := firstNonEmpty(opts.config, os.Getenv(envConfig)); path != "" {
+		return path
+	}
+	if dir := firstNonEmpty(opts.configDir, os.Getenv(envConfigDir)); dir != "" {
+		return filepath.Join(dir, configFileName)
+	}
+
+	candidates := []string{}
+	if dir := xdgDir("XDG_CONFIG_HOME", ".config"); dir != "" {
+		candidates = append(candidates, filepath.Join(dir, appName, configFileName))
+	}
+	candidates = append(candidates, filepath.Join("/etc", appName, configFileName))
+	for _, path := range candidates {
+		if isFile(path) {
+			return path
+		}
+	}
+	// keep compatible with early versions
+	return filepath.Join(binaryDir(), configFileName)
+}
+
+// resolveDataDir return the directory where data is stored, it's looked up
+// in order: --data-dir, TGBOT_DATA_DIR, data_dir of config, the directory of
+// binary if history was stored there by early versions and
+// $XDG_DATA_HOME/reminder-tgbot
+func resolveDataDir(opts dirOptions, configured string) string {
+	if dir := firstNonEmpty(opts.dataDir, os.Getenv(envDataDir), configured); dir != "" {
+		return dir
+	}
+	if bdir := binaryDir(); isDir(filepath.Join(bdir, "checkin_history")) {
+		return bdir
+	}
+	if dir := xdgDir("XDG_DATA_HOME", filepath.Join(".local", "share")); dir != "" {
+		return filepath.Join(dir, appName)
+	}
+	return filepath.Join(binaryDir(), "data")
+}
+
+// xdgDir return value of environment variable `env`, or `fallback` under
+// home directory if it's not set
+func xdgDir(env, fallback string) string {
+	if dir := os.Getenv(env); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, fallback)
+}
+
+// binaryDir return the directory of the running binary
+func binaryDir() string {
+	exe, err := os.Executable()
+	if err != nil {
+		exe = os.Args[0]
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	dir, _ := filepath.Abs(filepath.Dir(exe))
+	return dir
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
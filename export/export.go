@@ -0,0 +1,146 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/zhao-kun/reminder-tgbot/model"
+	"github.com/zhao-kun/reminder-tgbot/repo"
+)
+
+const (
+	// FormatCSV is plain UTF-8 csv
+	FormatCSV = "csv"
+	// FormatJSON is a json array of check in records
+	FormatJSON = "json"
+	// FormatExcel is csv with UTF-8 BOM, which Excel opens correctly
+	FormatExcel = "excel"
+)
+
+// utf8BOM tell Excel the csv file is encoded in UTF-8
+const utf8BOM = "\xEF\xBB\xBF"
+
+var (
+	csvHeader = []string{"id", "user", "date", "time", "status", "kind", "note",
+		"latitude", "longitude", "photo"}
+
+	contentTypes = map[string]string{
+		FormatCSV:   "text/csv; charset=utf-8",
+		FormatJSON:  "application/json; charset=utf-8",
+		FormatExcel: "text/csv; charset=utf-8",
+	}
+
+	extensions = map[string]string{
+		FormatCSV:   "csv",
+		FormatJSON:  "json",
+		FormatExcel: "csv",
+	}
+)
+
+// ParseFormat return the normalized format, empty string means FormatCSV
+func ParseFormat(format string) (string, error) {
+	format = strings.ToLower(format)
+	if format == "" {
+		return FormatCSV, nil
+	}
+	if format == "xlsx" {
+		return FormatExcel, nil
+	}
+	if _, ok := contentTypes[format]; !ok {
+		return "", fmt.Errorf("export format %s is not supported, use csv, json or excel", format)
+	}
+	return format, nil
+}
+
+// ContentType return the MIME type of `format`
+func ContentType(format string) string {
+	return contentTypes[format]
+}
+
+// FileName return a file name describe the exported `query` in `format`
+func FileName(format string, query model.CheckInQuery) string {
+	name := "checkins"
+	if query.User != "" {
+		name += "_" + query.User
+	}
+	if !query.From.IsZero() {
+		name += "_" + query.From.Format("20060102")
+	}
+	if !query.To.IsZero() {
+		name += "_" + query.To.Format("20060102")
+	}
+	return name + "." + extensions[format]
+}
+
+// Export write check in records of `r` matching `query` to `w` in `format`
+func Export(w io.Writer, r repo.Repo, format string, query model.CheckInQuery) error {
+	checkIns, err := r.CheckIns(query)
+	if err != nil {
+		return err
+	}
+	return Write(w, format, checkIns)
+}
+
+// Write write `checkIns` to `w` in `format`
+func Write(w io.Writer, format string, checkIns []model.CheckIn) error {
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(checkIns)
+	case FormatExcel:
+		if _, err := io.WriteString(w, utf8BOM); err != nil {
+			return err
+		}
+		return writeCSV(w, checkIns, escapeFormula)
+	case FormatCSV:
+		return writeCSV(w, checkIns, func(cell string) string { return cell })
+	}
+	return fmt.Errorf("export format %s is not supported", format)
+}
+
+// escapeFormula prefix `cell` with `'` if it starts with a character which
+// make Excel run it as a formula
+func escapeFormula(cell string) string {
+	if cell != "" && strings.ContainsAny(cell[:1], "=+-@\t\r") {
+		return "'" + cell
+	}
+	return cell
+}
+
+// writeCSV write `checkIns` as csv to `w`, fields given by users are
+// escaped by `escape`
+func writeCSV(w io.Writer, checkIns []model.CheckIn, escape func(string) string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, c := range checkIns {
+		var latitude, longitude string
+		if c.Location != nil {
+			latitude = strconv.FormatFloat(c.Location.Latitude, 'f', -1, 64)
+			longitude = strconv.FormatFloat(c.Location.Longitude, 'f', -1, 64)
+		}
+		record := []string{
+			escape(c.ID),
+			escape(c.User),
+			c.Time.Format("2006-01-02"),
+			c.Time.Format("15:04:05"),
+			c.Status,
+			c.Kind,
+			escape(c.Note),
+			latitude,
+			longitude,
+			escape(c.Photo),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/zhao-kun/reminder-tgbot/export"
+	"github.com/zhao-kun/reminder-tgbot/model"
+	"github.com/zhao-kun/reminder-tgbot/util"
+)
+
+// newExportCommand return `tgbot export` command which export check in
+// history to a file or stdout
+func newExportCommand(opts *dirOptions) *cobra.Command {
+	var group, user, from, to, format, output string
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export check in history as csv, json or excel compatible csv",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := export.ParseFormat(format)
+			if err != nil {
+				return err
+			}
+
+			query := model.CheckInQuery{User: user}
+			if from != "" {
+				if query.From, err = util.ParseChinaDate(from); err != nil {
+					return fmt.Errorf("from should be yyyy-mm-dd")
+				}
+			}
+			if to != "" {
+				if query.To, err = util.ParseChinaDate(to); err != nil {
+					return fmt.Errorf("to should be yyyy-mm-dd")
+				}
+			}
+
+			config, l, err := loadConf(*opts)
+			if err != nil {
+				return err
+			}
+
+			r, err := openGroup(config, l, group)
+			if err != nil {
+				return err
+			}
+
+			var w io.Writer = os.Stdout
+			if output != "" {
+				file, err := os.OpenFile(output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+				if err != nil {
+					return fmt.Errorf("open file %s error %s", output, err)
+				}
+				defer file.Close()
+				w = file
+			}
+			return export.Export(w, r, format, query)
+		},
+	}
+
+	cmd.Flags().StringVar(&group, "group", "", "group to export, default is the first group")
+	cmd.Flags().StringVar(&user, "user", "", "export check ins of the user only")
+	cmd.Flags().StringVar(&from, "from", "", "first date to export, yyyy-mm-dd")
+	cmd.Flags().StringVar(&to, "to", "", "last date to export, yyyy-mm-dd")
+	cmd.Flags().StringVar(&format, "format", export.FormatCSV, "csv, json or excel")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "output file, default is stdout")
+	return cmd
+}
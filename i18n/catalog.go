@@ -0,0 +1,327 @@
+package i18n
+
+import (
+	"strings"
+	"time"
+)
+
+// keys of messages, the builtin English message is shown as comment when
+// it has arguments
+const (
+	// Hi %s, you are good guy, no need to check every day
+	NotTracked Key = "not_tracked"
+	// Sorry, please check in at %s - %s
+	OutOfWindow       Key = "out_of_window"
+	SessionNotAllowed Key = "session_not_allowed"
+	// OK! you are checked in @%s
+	CheckedIn Key = "checked_in"
+	// OK! you are checked in @%s, but you are late
+	CheckedInLate    Key = "checked_in_late"
+	AlreadyCheckedIn Key = "already_checked_in"
+	CheckInFailed    Key = "check_in_failed"
+	// Hi @%s, you need to check in now
+	Reminder Key = "reminder"
+	// Hi %s, you need to check in now
+	BatchReminder Key = "batch_reminder"
+	AllCheckedIn  Key = "all_checked_in"
+
+	// Sorry @%s, only admins can do it
+	AdminOnly        Key = "admin_only"
+	UsernameRequired Key = "username_required"
+	PrivateOnly      Key = "private_only"
+	GroupChatOnly    Key = "group_chat_only"
+	InvalidRequest   Key = "invalid_request"
+	EditFailed       Key = "edit_failed"
+	Commands         Key = "commands"
+	AdminCommands    Key = "admin_commands"
+
+	ExportFailed Key = "export_failed"
+	// Check ins from %s to %s
+	ExportCaption Key = "export_caption"
+
+	// Welcome @%s, please check in every work day
+	Welcome Key = "welcome"
+	// @%s, you have joined already
+	AlreadyJoined Key = "already_joined"
+	// @%s wants to join, waiting for approval of an admin
+	JoinPending Key = "join_pending"
+	Approve     Key = "approve"
+	Reject      Key = "reject"
+	JoinFailed  Key = "join_failed"
+	// Welcome @%s, please check in every work day (approved by @%s)
+	JoinApproved Key = "join_approved"
+	// Sorry @%s, your request to join was rejected by @%s
+	JoinRejected Key = "join_rejected"
+	// Bye @%s, you don't need to check in any more
+	Bye Key = "bye"
+	// @%s, you haven't joined
+	NotJoined  Key = "not_joined"
+	QuitFailed Key = "quit_failed"
+
+	// Hi @%s, you aren't tracked by any group, ...
+	StartNotTracked Key = "start_not_tracked"
+	// Hi @%s, you are linked to group %s, ...
+	Linked             Key = "linked"
+	StartFirst         Key = "start_first"
+	SaveSettingsFailed Key = "save_settings_failed"
+	// Settings of @%s: ...
+	Settings Key = "settings"
+	// Reminder in private chat: %s
+	RemindDMButton Key = "remind_dm_button"
+	On             Key = "on"
+	Off            Key = "off"
+	None           Key = "none"
+
+	// @%s isn't tracked
+	UserNotTracked Key = "user_not_tracked"
+	// @%s has checked in at %s, use /amend instead
+	UseAmend       Key = "use_amend"
+	NothingToAmend Key = "nothing_to_amend"
+	// Check in of @%s at %s was recorded
+	Recorded Key = "recorded"
+	// Check in of @%s at %s was amended
+	Amended Key = "amended"
+	// Check in of @%s at %s was revoked
+	Revoked         Key = "revoked"
+	AuditFailed     Key = "audit_failed"
+	ReadAuditFailed Key = "read_audit_failed"
+	NoAudit         Key = "no_audit"
+	// Latest %d changes:
+	LatestChanges Key = "latest_changes"
+
+	// %s\nUsage: %s
+	ArgsInvalid         Key = "args_invalid"
+	UserAndDateRequired Key = "user_and_date_required"
+	DateFormat          Key = "date_format"
+	TimeFormat          Key = "time_format"
+	ReasonRequired      Key = "reason_required"
+	TooManyArgs         Key = "too_many_args"
+	FromFormat          Key = "from_format"
+	ToFormat            Key = "to_format"
+	UnknownFormat       Key = "unknown_format"
+	CheckInNotFound     Key = "check_in_not_found"
+	InvalidUser         Key = "invalid_user"
+)
+
+// Weekday return key of the short name of `d`, e.g. `mon`
+func Weekday(d time.Weekday) Key {
+	return Key(strings.ToLower(d.String()[:3]))
+}
+
+// Description return key of the description of `command`, e.g.
+// `description_checkin` of `/checkin`
+func Description(command string) Key {
+	return Key("description_" + strings.TrimPrefix(command, "/"))
+}
+
+// Usage return key of the usage of `command`, e.g. `usage_checkin` of
+// `/checkin`
+func Usage(command string) Key {
+	return Key("usage_" + strings.TrimPrefix(command, "/"))
+}
+
+const authorTips = "please contact the `reminder-tgbot` author."
+
+var catalog = map[string]map[Key]string{
+	English: {
+		NotTracked:        "Hi %s, you are good guy, no need to check every day",
+		OutOfWindow:       "Sorry, please check in at %s - %s",
+		SessionNotAllowed: "Sorry, current session isn't allowed to check in",
+		CheckedIn:         "OK! you are checked in @%s",
+		CheckedInLate:     "OK! you are checked in @%s, but you are late",
+		AlreadyCheckedIn:  "Yes, yes, you've already checked in.",
+		CheckInFailed:     "Sorry, check in failed, " + authorTips,
+		Reminder:          "Hi @%s, you need to check in now",
+		BatchReminder:     "Hi %s, you need to check in now",
+		AllCheckedIn:      "Everyone has checked in, thanks!",
+
+		AdminOnly:        "Sorry @%s, only admins can do it",
+		UsernameRequired: "Sorry, please set a username in Telegram settings first",
+		PrivateOnly:      "Sorry, please send it to me in a private chat",
+		GroupChatOnly:    "Sorry, please send it in a chat of your group",
+		InvalidRequest:   "Sorry, the request is invalid",
+		EditFailed:       "It's done, but the message couldn't be updated",
+		Commands:         "Commands:",
+		AdminCommands:    "Admin commands:",
+
+		ExportFailed:  "Sorry, export failed, " + authorTips,
+		ExportCaption: "Check ins from %s to %s",
+
+		Welcome:       "Welcome @%s, please check in every work day",
+		AlreadyJoined: "@%s, you have joined already",
+		JoinPending:   "@%s wants to join, waiting for approval of an admin",
+		Approve:       "Approve",
+		Reject:        "Reject",
+		JoinFailed:    "Sorry, join failed, " + authorTips,
+		JoinApproved:  "Welcome @%s, please check in every work day (approved by @%s)",
+		JoinRejected:  "Sorry @%s, your request to join was rejected by @%s",
+		Bye:           "Bye @%s, you don't need to check in any more",
+		NotJoined:     "@%s, you haven't joined",
+		QuitFailed:    "Sorry, quit failed, " + authorTips,
+
+		StartNotTracked: "Hi @%s, you aren't tracked by any group, " +
+			"please send /join in the chat of your group first",
+		Linked: "Hi @%s, you are linked to group %s, " +
+			"send /settings to change your personal settings",
+		StartFirst:         "Sorry, please send /start first",
+		SaveSettingsFailed: "Sorry, save settings failed, " + authorTips,
+		Settings: "Settings of @%s:\nReminder in private chat: %s\nLanguage: %s\n" +
+			"Quiet days: %s",
+		RemindDMButton: "Reminder in private chat: %s",
+		On:             "on",
+		Off:            "off",
+		None:           "none",
+		"sun":          "Sun",
+		"mon":          "Mon",
+		"tue":          "Tue",
+		"wed":          "Wed",
+		"thu":          "Thu",
+		"fri":          "Fri",
+		"sat":          "Sat",
+
+		UserNotTracked:  "@%s isn't tracked",
+		UseAmend:        "@%s has checked in at %s, use /amend instead",
+		NothingToAmend:  "Nothing to amend",
+		Recorded:        "Check in of @%s at %s was recorded",
+		Amended:         "Check in of @%s at %s was amended",
+		Revoked:         "Check in of @%s at %s was revoked",
+		AuditFailed:     ", but writing audit log failed, " + authorTips,
+		ReadAuditFailed: "Sorry, read audit log failed, " + authorTips,
+		NoAudit:         "No check in was changed by admins",
+		LatestChanges:   "Latest %d changes:",
+
+		ArgsInvalid:         "%s\nUsage: %s",
+		UserAndDateRequired: "user and date are required",
+		DateFormat:          "date should be yyyy-mm-dd",
+		TimeFormat:          "time should be hh:mm",
+		ReasonRequired:      "reason is required",
+		TooManyArgs:         "too many arguments",
+		FromFormat:          "from should be yyyy-mm-dd",
+		ToFormat:            "to should be yyyy-mm-dd",
+		UnknownFormat:       "format should be csv, json or excel",
+		CheckInNotFound:     "check in isn't found",
+		InvalidUser:         "user name is invalid",
+
+		"description_checkin":  "Check in, the kind and a note are optional",
+		"description_join":     "Start checking in in this chat",
+		"description_quit":     "Stop checking in in this chat",
+		"description_start":    "Link this private chat to you",
+		"description_settings": "Change your personal settings in private chat",
+		"description_help":     "Show commands",
+		"description_export":   "Export check ins of the current month or the date range",
+		"description_record":   "Record a missed check in",
+		"description_amend":    "Change time, kind or status of a check in",
+		"description_revoke":   "Remove a check in",
+		"description_audit":    "Show the latest changes made by admins",
+		"usage_checkin":        "/checkin [office|wfh] [note]",
+		"usage_join":           "/join",
+		"usage_quit":           "/quit",
+		"usage_start":          "/start",
+		"usage_settings":       "/settings",
+		"usage_help":           "/help",
+		"usage_export":         "/export [csv|json|excel] [from yyyy-mm-dd] [to yyyy-mm-dd]",
+		"usage_record":         "/record user yyyy-mm-dd [hh:mm] [office|wfh] reason",
+		"usage_amend":          "/amend user yyyy-mm-dd [hh:mm] [office|wfh] [on_time|late] reason",
+		"usage_revoke":         "/revoke user yyyy-mm-dd reason",
+		"usage_audit":          "/audit [user]",
+	},
+	Chinese: {
+		NotTracked:        "你好 %s，你不需要每天打卡",
+		OutOfWindow:       "抱歉，请在 %s - %s 之间打卡",
+		SessionNotAllowed: "抱歉，当前会话不能打卡",
+		CheckedIn:         "好的！@%s 已打卡",
+		CheckedInLate:     "好的！@%s 已打卡，但是迟到了",
+		AlreadyCheckedIn:  "是的是的，你已经打过卡了。",
+		CheckInFailed:     "抱歉，打卡失败，请联系 `reminder-tgbot` 的作者。",
+		Reminder:          "@%s 你好，现在需要打卡了",
+		BatchReminder:     "%s 你好，现在需要打卡了",
+		AllCheckedIn:      "大家都打卡了，谢谢！",
+
+		AdminOnly:        "抱歉 @%s，只有管理员可以这样做",
+		UsernameRequired: "抱歉，请先在 Telegram 设置中设置用户名",
+		PrivateOnly:      "抱歉，请在私聊中发给我",
+		GroupChatOnly:    "抱歉，请在你的群组的聊天中发送",
+		InvalidRequest:   "抱歉，请求无效",
+		EditFailed:       "已完成，但消息无法更新",
+		Commands:         "命令：",
+		AdminCommands:    "管理员命令：",
+
+		ExportFailed:  "抱歉，导出失败，请联系 `reminder-tgbot` 的作者。",
+		ExportCaption: "%s 至 %s 的打卡记录",
+
+		Welcome:       "欢迎 @%s，请每个工作日打卡",
+		AlreadyJoined: "@%s，你已经加入了",
+		JoinPending:   "@%s 申请加入，等待管理员批准",
+		Approve:       "批准",
+		Reject:        "拒绝",
+		JoinFailed:    "抱歉，加入失败，请联系 `reminder-tgbot` 的作者。",
+		JoinApproved:  "欢迎 @%s，请每个工作日打卡（由 @%s 批准）",
+		JoinRejected:  "抱歉 @%s，你的加入申请被 @%s 拒绝了",
+		Bye:           "再见 @%s，你不需要再打卡了",
+		NotJoined:     "@%s，你还没有加入",
+		QuitFailed:    "抱歉，退出失败，请联系 `reminder-tgbot` 的作者。",
+
+		StartNotTracked:    "@%s 你好，你不属于任何小组，请先在小组的聊天中发送 /join",
+		Linked:             "@%s 你好，你已关联到小组 %s，发送 /settings 修改个人设置",
+		StartFirst:         "抱歉，请先发送 /start",
+		SaveSettingsFailed: "抱歉，保存设置失败，请联系 `reminder-tgbot` 的作者。",
+		Settings:           "@%s 的设置：\n私聊提醒：%s\n语言：%s\n免打扰日：%s",
+		RemindDMButton:     "私聊提醒：%s",
+		On:                 "开",
+		Off:                "关",
+		None:               "无",
+		"sun":              "周日",
+		"mon":              "周一",
+		"tue":              "周二",
+		"wed":              "周三",
+		"thu":              "周四",
+		"fri":              "周五",
+		"sat":              "周六",
+
+		UserNotTracked:  "@%s 不需要打卡",
+		UseAmend:        "@%s 已在 %s 打卡，请使用 /amend",
+		NothingToAmend:  "没有需要修改的内容",
+		Recorded:        "已补录 @%s 在 %s 的打卡",
+		Amended:         "已修改 @%s 在 %s 的打卡",
+		Revoked:         "已撤销 @%s 在 %s 的打卡",
+		AuditFailed:     "，但是写审计日志失败，请联系 `reminder-tgbot` 的作者。",
+		ReadAuditFailed: "抱歉，读取审计日志失败，请联系 `reminder-tgbot` 的作者。",
+		NoAudit:         "管理员没有修改过打卡记录",
+		LatestChanges:   "最近 %d 次修改：",
+
+		ArgsInvalid:         "%s\n用法：%s",
+		UserAndDateRequired: "需要用户和日期",
+		DateFormat:          "日期格式应为 yyyy-mm-dd",
+		TimeFormat:          "时间格式应为 hh:mm",
+		ReasonRequired:      "需要原因",
+		TooManyArgs:         "参数太多",
+		FromFormat:          "开始日期格式应为 yyyy-mm-dd",
+		ToFormat:            "结束日期格式应为 yyyy-mm-dd",
+		UnknownFormat:       "格式应为 csv、json 或 excel",
+		CheckInNotFound:     "找不到打卡记录",
+		InvalidUser:         "用户名无效",
+
+		"description_checkin":  "打卡，类型和备注可选",
+		"description_join":     "开始在这个聊天中打卡",
+		"description_quit":     "停止在这个聊天中打卡",
+		"description_start":    "将这个私聊关联到你",
+		"description_settings": "在私聊中修改个人设置",
+		"description_help":     "显示命令",
+		"description_export":   "导出本月或指定日期范围的打卡记录",
+		"description_record":   "补录漏掉的打卡",
+		"description_amend":    "修改打卡的时间、类型或状态",
+		"description_revoke":   "撤销打卡",
+		"description_audit":    "显示管理员最近的修改",
+		"usage_checkin":        "/checkin [office|wfh] [备注]",
+		"usage_join":           "/join",
+		"usage_quit":           "/quit",
+		"usage_start":          "/start",
+		"usage_settings":       "/settings",
+		"usage_help":           "/help",
+		"usage_export":         "/export [csv|json|excel] [开始 yyyy-mm-dd] [结束 yyyy-mm-dd]",
+		"usage_record":         "/record 用户 yyyy-mm-dd [hh:mm] [office|wfh] 原因",
+		"usage_amend":          "/amend 用户 yyyy-mm-dd [hh:mm] [office|wfh] [on_time|late] 原因",
+		"usage_revoke":         "/revoke 用户 yyyy-mm-dd 原因",
+		"usage_audit":          "/audit [用户]",
+	},
+}
@@ -0,0 +1,109 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Key identify a message of the catalog
+type Key string
+
+const (
+	// English is the default language
+	English = "en"
+	// Chinese is Simplified Chinese
+	Chinese = "zh-CN"
+)
+
+// Languages is supported languages, the first one is the default
+var Languages = []string{English, Chinese}
+
+// Language return the supported language matching language code `code` of
+// Telegram, e.g. `zh-hans` is Chinese, it's English if no one matches
+func Language(code string) string {
+	code = strings.ToLower(code)
+	for _, lang := range Languages {
+		if strings.ToLower(lang) == code {
+			return lang
+		}
+	}
+	if strings.HasPrefix(code, "zh") {
+		return Chinese
+	}
+	return English
+}
+
+// T return message `key` in language `lang` formatted with `args` by
+// fmt.Sprintf, messages of `overrides` take precedence over the builtin
+// ones, the default language is used if `lang` isn't supported
+func T(overrides map[string]map[string]string, lang string, key Key,
+	args ...interface{}) string {
+	if _, ok := catalog[lang]; !ok {
+		lang = Language(lang)
+	}
+	format, ok := overrides[lang][string(key)]
+	if !ok {
+		if format, ok = catalog[lang][key]; !ok {
+			format = catalog[English][key]
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// ValidateLanguage return error if `lang` isn't empty and isn't a supported
+// language
+func ValidateLanguage(lang string) error {
+	if _, ok := catalog[lang]; ok || lang == "" {
+		return nil
+	}
+	return fmt.Errorf("language %s isn't supported, supported languages are %s", lang,
+		strings.Join(Languages, ", "))
+}
+
+// Validate return error if `overrides` contains an unsupported language, an
+// unknown key, or a message whose number of verbs isn't the same as the
+// builtin one
+func Validate(overrides map[string]map[string]string) error {
+	for lang, messages := range overrides {
+		if _, ok := catalog[lang]; !ok {
+			return fmt.Errorf("language %s of messages isn't supported, supported languages "+
+				"are %s", lang, strings.Join(Languages, ", "))
+		}
+		var keys []string
+		for key := range messages {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			builtin, ok := catalog[English][Key(key)]
+			if !ok {
+				return fmt.Errorf("message %s of language %s is unknown", key, lang)
+			}
+			if verbs(messages[key]) != verbs(builtin) {
+				return fmt.Errorf("message %s of language %s should contain %d verbs like %q",
+					key, lang, verbs(builtin), builtin)
+			}
+		}
+	}
+	return nil
+}
+
+// verbs return number of formatting verbs of `format`
+func verbs(format string) int {
+	n := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		if i+1 < len(format) && format[i+1] == '%' {
+			i++
+			continue
+		}
+		n++
+	}
+	return n
+}
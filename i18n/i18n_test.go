@@ -0,0 +1,116 @@
+package i18n
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides map[string]map[string]string
+		err       bool
+	}{
+		{name: "no override"},
+		{
+			name:      "same verbs",
+			overrides: map[string]map[string]string{English: {"checked_in": "Got it @%s"}},
+		},
+		{
+			name:      "message without verb",
+			overrides: map[string]map[string]string{Chinese: {"all_checked_in": "都打卡了"}},
+		},
+		{
+			name:      "escaped percent isn't a verb",
+			overrides: map[string]map[string]string{English: {"checked_in": "100%% done @%s"}},
+		},
+		{
+			name:      "usage of a command",
+			overrides: map[string]map[string]string{English: {"usage_export": "/export"}},
+		},
+		{
+			name:      "unsupported language",
+			overrides: map[string]map[string]string{"fr": {"checked_in": "Merci @%s"}},
+			err:       true,
+		},
+		{
+			name:      "unknown key",
+			overrides: map[string]map[string]string{English: {"hello": "hello"}},
+			err:       true,
+		},
+		{
+			name:      "fewer verbs",
+			overrides: map[string]map[string]string{English: {"checked_in": "Got it"}},
+			err:       true,
+		},
+		{
+			name:      "more verbs",
+			overrides: map[string]map[string]string{Chinese: {"checked_in": "@%s %s"}},
+			err:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.overrides); (err != nil) != tt.err {
+				t.Errorf("error is %v, want error %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestValidateLanguage(t *testing.T) {
+	tests := []struct {
+		lang string
+		err  bool
+	}{
+		{lang: ""},
+		{lang: English},
+		{lang: Chinese},
+		{lang: "zh", err: true},
+		{lang: "fr", err: true},
+	}
+	for _, tt := range tests {
+		if err := ValidateLanguage(tt.lang); (err != nil) != tt.err {
+			t.Errorf("%q: error is %v, want error %v", tt.lang, err, tt.err)
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	overrides := map[string]map[string]string{Chinese: {"checked_in": "收到 @%s"}}
+	bob := []interface{}{"bob"}
+	tests := []struct {
+		name string
+		lang string
+		key  Key
+		args []interface{}
+		want string
+	}{
+		{name: "builtin", args: bob, lang: English, key: CheckedIn, want: "OK! you are checked in @bob"},
+		{name: "override", args: bob, lang: Chinese, key: CheckedIn, want: "收到 @bob"},
+		{name: "telegram language code", args: bob, lang: "zh-hans", key: CheckedIn, want: "收到 @bob"},
+		{name: "default language", args: bob, lang: "", key: CheckedIn, want: "OK! you are checked in @bob"},
+		{name: "unsupported language", args: bob, lang: "fr", key: CheckedIn, want: "OK! you are checked in @bob"},
+		{name: "without arguments", lang: Chinese, key: AllCheckedIn, want: catalog[Chinese][AllCheckedIn]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := T(overrides, tt.lang, tt.key, tt.args...); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	for _, lang := range Languages {
+		for key, message := range catalog[lang] {
+			builtin, ok := catalog[English][key]
+			if !ok {
+				t.Errorf("message %s of %s isn't in %s", key, lang, English)
+				continue
+			}
+			if verbs(message) != verbs(builtin) {
+				t.Errorf("message %s of %s has %d verbs, want %d", key, lang, verbs(message),
+					verbs(builtin))
+			}
+		}
+	}
+}
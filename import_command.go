@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/zhao-kun/reminder-tgbot/importer"
+)
+
+// newImportCommand return `tgbot import` command which import legacy check
+// in history into the repo
+func newImportCommand(opts *dirOptions) *cobra.Command {
+	var group, source string
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import legacy checkin_history/<user>/<yyyy>/<mm>/<dd>/checkin files",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, l, err := loadConf(*opts)
+			if err != nil {
+				return err
+			}
+
+			r, err := openGroup(config, l, group)
+			if err != nil {
+				return err
+			}
+			if source == "" {
+				source = r.CheckInDir()
+			}
+			result, err := importer.Import(r, source, l, importer.Options{DryRun: dryRun})
+			if err != nil {
+				return err
+			}
+
+			verb := "imported"
+			if dryRun {
+				verb = "would be imported"
+			}
+			fmt.Printf("%d check ins %s, %d already exist, %d failed\n",
+				result.Imported, verb, result.Skipped, len(result.Failures))
+			for _, f := range result.Failures {
+				fmt.Printf("  %s: %s\n", f.File, f.Err)
+			}
+			if len(result.Failures) > 0 {
+				return fmt.Errorf("%d check ins failed to import", len(result.Failures))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&group, "group", "", "group to import into, default is the first group")
+	cmd.Flags().StringVar(&source, "source", "",
+		"directory of legacy check in history, default is the directory of repo")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "only report what would be imported")
+	return cmd
+}
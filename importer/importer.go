@@ -0,0 +1,146 @@
+package importer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/zhao-kun/reminder-tgbot/logging"
+	"github.com/zhao-kun/reminder-tgbot/model"
+	"github.com/zhao-kun/reminder-tgbot/repo"
+	"github.com/zhao-kun/reminder-tgbot/util"
+)
+
+const (
+	// legacyFileName is the check in file written by early versions, its
+	// content is `<user> checkin at <time>`
+	legacyFileName = "checkin"
+	// legacyTimeLayout is the layout of time written by `%+v`
+	legacyTimeLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+)
+
+type (
+	// Options control behavior of Import
+	Options struct {
+		// DryRun only report what would be imported
+		DryRun bool
+	}
+
+	// Failure represent a legacy file which can't be imported
+	Failure struct {
+		File string
+		Err  error
+	}
+
+	// Result summarize an import
+	Result struct {
+		// Imported is number of records imported, or would be imported in
+		// dry run
+		Imported int
+		// Skipped is number of records which already exist in repo
+		Skipped  int
+		Failures []Failure
+	}
+)
+
+// Import walk `source` laid out as `<user>/<yyyy>/<mm>/<dd>/checkin` and
+// record each legacy check in into `r`, records already in `r` are skipped,
+// so it's safe to run again
+func Import(r repo.Repo, source string, l logging.Logger, opts Options) (Result, error) {
+	var result Result
+	files, err := filepath.Glob(filepath.Join(source, "*", "*", "*", "*", legacyFileName))
+	if err != nil {
+		return result, err
+	}
+
+	for _, file := range files {
+		checkIn, err := parseLegacyFile(source, file)
+		if err != nil {
+			l.Warn("parse legacy check in failed", logging.F("file", file), logging.Err(err))
+			result.Failures = append(result.Failures, Failure{file, err})
+			continue
+		}
+
+		_, err = r.Get(checkIn.ID)
+		if err == nil {
+			result.Skipped++
+			continue
+		}
+		if err != repo.ErrCheckInNotFound {
+			result.Failures = append(result.Failures, Failure{file, err})
+			continue
+		}
+
+		if !opts.DryRun {
+			err = r.Record(checkIn)
+			if err == repo.ErrAlreadyCheckedIn {
+				result.Skipped++
+				continue
+			}
+			if err != nil {
+				l.Error("import check in failed", logging.F("file", file), logging.Err(err))
+				result.Failures = append(result.Failures, Failure{file, err})
+				continue
+			}
+		}
+		l.Debug("check in was imported", logging.F("file", file),
+			logging.F("id", checkIn.ID), logging.F("dry_run", opts.DryRun))
+		result.Imported++
+	}
+	return result, nil
+}
+
+// parseLegacyFile parse the legacy check in `file`, user is the first
+// directory under `source`
+func parseLegacyFile(source string, file string) (model.CheckIn, error) {
+	rel, err := filepath.Rel(source, file)
+	if err != nil {
+		return model.CheckIn{}, err
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) != 5 {
+		return model.CheckIn{}, fmt.Errorf("path isn't <user>/<yyyy>/<mm>/<dd>/checkin")
+	}
+	user := parts[0]
+	date, err := util.ParseChinaDate(fmt.Sprintf("%s-%s-%s", parts[1], parts[2], parts[3]))
+	if err != nil {
+		return model.CheckIn{}, fmt.Errorf("path isn't a date: %s", err)
+	}
+
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return model.CheckIn{}, err
+	}
+	fields := strings.SplitN(strings.TrimSpace(string(content)), " checkin at ", 2)
+	if len(fields) != 2 {
+		return model.CheckIn{}, fmt.Errorf("content isn't `<user> checkin at <time>`")
+	}
+	// drop monotonic clock reading, e.g. ` m=+0.000000001`
+	timeStr := strings.SplitN(fields[1], " m=", 2)[0]
+	t, err := time.Parse(legacyTimeLayout, timeStr)
+	if err != nil {
+		return model.CheckIn{}, fmt.Errorf("parse time %s error %s", timeStr, err)
+	}
+	t = util.GetChinaTimeFromUnix(t.Unix())
+	if util.GetDate(t) != util.GetDate(date) {
+		return model.CheckIn{}, fmt.Errorf("time %s doesn't match date of path", timeStr)
+	}
+
+	return model.CheckIn{
+		ID:   repo.CheckInID(user, t),
+		User: user,
+		Time: t,
+	}, nil
+}
+
+// Pending return number of legacy records in `source` which aren't in `r`
+func Pending(r repo.Repo, source string, l logging.Logger) (int, error) {
+	if _, err := os.Stat(source); os.IsNotExist(err) {
+		return 0, nil
+	}
+	result, err := Import(r, source, l, Options{DryRun: true})
+	return result.Imported, err
+}
@@ -0,0 +1,179 @@
+package importer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zhao-kun/reminder-tgbot/logging"
+	"github.com/zhao-kun/reminder-tgbot/model"
+	"github.com/zhao-kun/reminder-tgbot/repo"
+	"github.com/zhao-kun/reminder-tgbot/util"
+)
+
+// legacyContent return content of a legacy check in file written at `t`
+func legacyContent(user string, t time.Time) string {
+	return fmt.Sprintf("%s checkin at %+v", user, t)
+}
+
+func writeLegacyFile(t *testing.T, source, path, content string) string {
+	file := filepath.Join(source, filepath.FromSlash(path), legacyFileName)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLegacyTimeLayout(t *testing.T) {
+	china := util.GetChinaTimeFromUnix(0).Location()
+	tests := []struct {
+		name string
+		t    time.Time
+	}{
+		{name: "china time", t: time.Date(2020, 5, 6, 9, 30, 15, 123456789, china)},
+		{name: "without nanoseconds", t: time.Date(2020, 5, 6, 9, 30, 15, 0, china)},
+		{name: "utc", t: time.Date(2020, 5, 6, 1, 30, 15, 0, time.UTC)},
+		{name: "with monotonic clock reading", t: time.Now().In(china)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// `%+v` is how early versions wrote the time
+			value := fmt.Sprintf("%+v", tt.t)
+			dir, err := ioutil.TempDir("", "importer")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			date := util.GetChinaTimeFromUnix(tt.t.Unix()).Format("2006/01/02")
+			file := writeLegacyFile(t, dir, "alice/"+date, "alice checkin at "+value)
+
+			checkIn, err := parseLegacyFile(dir, file)
+			if err != nil {
+				t.Fatalf("parse %s error %s", value, err)
+			}
+			if !checkIn.Time.Equal(tt.t.Truncate(time.Second)) {
+				t.Errorf("got %s, want %s", checkIn.Time, tt.t)
+			}
+		})
+	}
+}
+
+func TestParseLegacyFile(t *testing.T) {
+	checkInTime := time.Date(2020, 5, 6, 9, 30, 15, 0, util.GetChinaTimeFromUnix(0).Location())
+	tests := []struct {
+		name    string
+		path    string
+		content string
+		err     bool
+	}{
+		{name: "valid", path: "alice/2020/05/06", content: legacyContent("alice", checkInTime)},
+		{
+			name:    "trailing newline",
+			path:    "alice/2020/05/06",
+			content: legacyContent("alice", checkInTime) + "\n",
+		},
+		{name: "malformed content", path: "alice/2020/05/06", content: "alice was here", err: true},
+		{name: "malformed time", path: "alice/2020/05/06", content: "alice checkin at yesterday", err: true},
+		{name: "path isn't a date", path: "alice/2020/13/06", content: legacyContent("alice", checkInTime), err: true},
+		{
+			name:    "time doesn't match date of path",
+			path:    "alice/2020/05/07",
+			content: legacyContent("alice", checkInTime),
+			err:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "importer")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			file := writeLegacyFile(t, dir, tt.path, tt.content)
+
+			checkIn, err := parseLegacyFile(dir, file)
+			if (err != nil) != tt.err {
+				t.Fatalf("error is %v, want error %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			want := model.CheckIn{
+				ID:   repo.CheckInID("alice", checkInTime),
+				User: "alice",
+				Time: checkInTime,
+			}
+			if checkIn.ID != want.ID || checkIn.User != want.User || !checkIn.Time.Equal(want.Time) {
+				t.Errorf("got %+v, want %+v", checkIn, want)
+			}
+		})
+	}
+}
+
+func TestImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "importer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	l, err := logging.New(model.Log{}, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := repo.New(model.Config{DataDir: dir}, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	china := util.GetChinaTimeFromUnix(0).Location()
+	first := time.Date(2020, 5, 6, 9, 30, 15, 0, china)
+	second := time.Date(2020, 5, 7, 10, 0, 0, 0, china)
+	source := r.CheckInDir()
+	writeLegacyFile(t, source, "alice/2020/05/06", legacyContent("alice", first))
+	writeLegacyFile(t, source, "bob/2020/05/07", legacyContent("bob", second))
+	malformed := writeLegacyFile(t, source, "bob/2020/05/08", "bob checkin at")
+
+	tests := []struct {
+		name   string
+		opts   Options
+		result Result
+	}{
+		{name: "dry run", opts: Options{DryRun: true}, result: Result{Imported: 2}},
+		{name: "import", result: Result{Imported: 2}},
+		{name: "import again", result: Result{Skipped: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Import(r, source, l, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Imported != tt.result.Imported || result.Skipped != tt.result.Skipped {
+				t.Errorf("imported %d and skipped %d, want %d and %d", result.Imported,
+					result.Skipped, tt.result.Imported, tt.result.Skipped)
+			}
+			if len(result.Failures) != 1 || result.Failures[0].File != malformed {
+				t.Errorf("failures are %v, want %s", result.Failures, malformed)
+			}
+		})
+	}
+
+	for user, want := range map[string]time.Time{"alice": first, "bob": second} {
+		checkIn, err := r.Get(repo.CheckInID(user, want))
+		if err != nil {
+			t.Fatalf("check in of %s isn't imported: %s", user, err)
+		}
+		if !checkIn.Time.Equal(want) {
+			t.Errorf("check in of %s is at %s, want %s", user, checkIn.Time, want)
+		}
+	}
+	if pending, err := Pending(r, source, l); err != nil || pending != 0 {
+		t.Errorf("%d records are pending, error %v", pending, err)
+	}
+}
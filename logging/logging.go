@@ -0,0 +1,341 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zhao-kun/reminder-tgbot/model"
+)
+
+// Level represent the severity of a log message
+type Level int
+
+const (
+	// LevelDebug is used to log verbose message, e.g. raw request body
+	LevelDebug Level = iota
+	// LevelInfo is the default level
+	LevelInfo
+	// LevelWarn is used to log unexpected but recoverable situation
+	LevelWarn
+	// LevelError is used to log failure
+	LevelError
+)
+
+const (
+	// FormatLogfmt output log as `key=value` pairs, it's the default format
+	FormatLogfmt = "logfmt"
+	// FormatJSON output every log as a json object
+	FormatJSON = "json"
+
+	redacted = "[REDACTED]"
+)
+
+type (
+	// Field is a key value pair attached to a log message
+	Field struct {
+		Key   string
+		Value interface{}
+	}
+
+	// Logger is a structured leveled logger
+	Logger interface {
+		Debug(msg string, fields ...Field)
+		Info(msg string, fields ...Field)
+		Warn(msg string, fields ...Field)
+		Error(msg string, fields ...Field)
+		// With return a Logger which attach `fields` to every message
+		With(fields ...Field) Logger
+		// Enabled return whether message of `level` will be logged
+		Enabled(level Level) bool
+		// Redact replace secrets and, if it's configured, personal data
+		// contained in `s` with [REDACTED]
+		Redact(s string) string
+	}
+
+	// output is shared by a logger and all loggers derived by With
+	output struct {
+		sync.Mutex
+		w              io.Writer
+		level          Level
+		format         string
+		secrets        []string
+		redactPersonal bool
+	}
+
+	logger struct {
+		out    *output
+		fields []Field
+	}
+
+	// stdWriter adapt Logger to io.Writer for the standard log package
+	stdWriter struct {
+		l     Logger
+		level Level
+	}
+)
+
+var _ Logger = logger{}
+
+var (
+	levelNames = map[Level]string{
+		LevelDebug: "debug",
+		LevelInfo:  "info",
+		LevelWarn:  "warn",
+		LevelError: "error",
+	}
+
+	// botTokenPattern match the token of any telegram bot, e.g.
+	// 123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11
+	botTokenPattern = regexp.MustCompile(`\d{5,}:[A-Za-z0-9_-]{30,}`)
+	// personalFieldPattern match fields of telegram json which contain
+	// personal data
+	personalFieldPattern = regexp.MustCompile(
+		`"(username|first_name|last_name)"\s*:\s*"(\\.|[^"\\])*"`)
+	// personalKeys is keys of Field which value is personal data
+	personalKeys = map[string]bool{"user": true, "member": true}
+	// mentionPattern match a telegram mention, e.g. @some_one
+	mentionPattern = regexp.MustCompile(`@[A-Za-z0-9_]{3,}`)
+)
+
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+// ParseLevel convert `level` to Level, empty string means LevelInfo
+func ParseLevel(level string) (Level, error) {
+	if level == "" {
+		return LevelInfo, nil
+	}
+	for l, name := range levelNames {
+		if strings.EqualFold(name, level) {
+			return l, nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("log level %s is not supported", level)
+}
+
+// F return a Field
+func F(key string, value interface{}) Field {
+	return Field{key, value}
+}
+
+// Err return a Field represent an error
+func Err(err error) Field {
+	return Field{"error", err}
+}
+
+// ChatID return a Field represent the chat a message belongs to
+func ChatID(id int64) Field {
+	return Field{"chat_id", id}
+}
+
+// User return a Field represent a telegram user name
+func User(user string) Field {
+	return Field{"user", user}
+}
+
+// UpdateID return a Field represent the id of a telegram update
+func UpdateID(id int) Field {
+	return Field{"update_id", id}
+}
+
+// Task return a Field represent the name of a task
+func Task(name string) Field {
+	return Field{"task", name}
+}
+
+// New return a Logger configured by `cfg` which write to `w`, each
+// `secret` will be replaced by [REDACTED] in every log message
+func New(cfg model.Log, w io.Writer, secret ...string) (Logger, error) {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	format := strings.ToLower(cfg.Format)
+	switch format {
+	case "":
+		format = FormatLogfmt
+	case FormatLogfmt, FormatJSON:
+	default:
+		return nil, fmt.Errorf("log format %s is not supported", cfg.Format)
+	}
+
+	out := &output{
+		w:              w,
+		level:          level,
+		format:         format,
+		redactPersonal: cfg.RedactPersonalData,
+	}
+	for _, s := range secret {
+		if s != "" {
+			out.secrets = append(out.secrets, s)
+		}
+	}
+	return logger{out: out}, nil
+}
+
+// NewWriter return a io.Writer which log each written line at `level`,
+// it's used to redirect log of the standard log package and third party
+// libraries to `l`
+func NewWriter(l Logger, level Level) io.Writer {
+	return stdWriter{l, level}
+}
+
+func (w stdWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		switch w.level {
+		case LevelDebug:
+			w.l.Debug(line)
+		case LevelWarn:
+			w.l.Warn(line)
+		case LevelError:
+			w.l.Error(line)
+		default:
+			w.l.Info(line)
+		}
+	}
+	return len(p), nil
+}
+
+// RedirectStdLog make the standard log package write to `l`
+func RedirectStdLog(l Logger) {
+	log.SetFlags(0)
+	log.SetOutput(NewWriter(l, LevelInfo))
+}
+
+func (l logger) Debug(msg string, fields ...Field) {
+	l.log(LevelDebug, msg, fields)
+}
+
+func (l logger) Info(msg string, fields ...Field) {
+	l.log(LevelInfo, msg, fields)
+}
+
+func (l logger) Warn(msg string, fields ...Field) {
+	l.log(LevelWarn, msg, fields)
+}
+
+func (l logger) Error(msg string, fields ...Field) {
+	l.log(LevelError, msg, fields)
+}
+
+func (l logger) With(fields ...Field) Logger {
+	merged := make([]Field, 0, len(l.fields)+len(fields))
+	merged = append(merged, l.fields...)
+	merged = append(merged, fields...)
+	return logger{out: l.out, fields: merged}
+}
+
+func (l logger) Enabled(level Level) bool {
+	return level >= l.out.level
+}
+
+func (l logger) Redact(s string) string {
+	for _, secret := range l.out.secrets {
+		s = strings.Replace(s, secret, redacted, -1)
+	}
+	s = botTokenPattern.ReplaceAllString(s, redacted)
+	if l.out.redactPersonal {
+		s = personalFieldPattern.ReplaceAllString(s, `"$1":"`+redacted+`"`)
+		s = mentionPattern.ReplaceAllString(s, "@"+redacted)
+	}
+	return s
+}
+
+func (l logger) log(level Level, msg string, fields []Field) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	all := make([]Field, 0, len(l.fields)+len(fields)+3)
+	all = append(all, F("time", time.Now().Format(time.RFC3339Nano)),
+		F("level", level.String()), F("msg", l.Redact(msg)))
+	for _, f := range l.fields {
+		all = append(all, l.redactField(f))
+	}
+	for _, f := range fields {
+		all = append(all, l.redactField(f))
+	}
+
+	var line string
+	if l.out.format == FormatJSON {
+		line = encodeJSON(all)
+	} else {
+		line = encodeLogfmt(all)
+	}
+	line += "\n"
+
+	l.out.Lock()
+	defer l.out.Unlock()
+	io.WriteString(l.out.w, line)
+}
+
+// redactField redact value of `f` before it's encoded, since encoding may
+// escape the data need to be redacted
+func (l logger) redactField(f Field) Field {
+	if l.out.redactPersonal && personalKeys[f.Key] {
+		return F(f.Key, redacted)
+	}
+	if s, ok := fieldValue(f.Value).(string); ok {
+		return F(f.Key, l.Redact(s))
+	}
+	return f
+}
+
+func fieldValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	case []byte:
+		return string(v)
+	}
+	return value
+}
+
+func encodeJSON(fields []Field) string {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(f.Key)
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := json.Marshal(fieldValue(f.Value))
+		if err != nil {
+			value, _ = json.Marshal(fmt.Sprintf("%+v", f.Value))
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.String()
+}
+
+func encodeLogfmt(fields []Field) string {
+	var buf bytes.Buffer
+	for i, f := range fields {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(f.Key)
+		buf.WriteByte('=')
+		value := fmt.Sprintf("%+v", fieldValue(f.Value))
+		if value == "" || strings.ContainsAny(value, " =\"\t\r\n") {
+			value = strconv.Quote(value)
+		}
+		buf.WriteString(value)
+	}
+	return buf.String()
+}
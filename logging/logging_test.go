@@ -0,0 +1,246 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zhao-kun/reminder-tgbot/model"
+)
+
+const botToken = "123456789:ABCdefGHIjklMNOpqrSTUvwxYZ0123456789"
+
+func TestRedact(t *testing.T) {
+	body := []byte(`{"message":{"from":{"username":"alice","first_name":"Al \"A\""},"text":"hi"}}`)
+	tests := []struct {
+		name     string
+		personal bool
+		msg      string
+		field    Field
+		// want is the logged message and the value of field
+		want      string
+		wantField string
+	}{
+		{
+			name:      "secret",
+			msg:       "request https://api.telegram.org/botsecret/getMe",
+			field:     Err(errors.New("get https://api.telegram.org/botsecret/getMe failed")),
+			want:      "request https://api.telegram.org/bot[REDACTED]/getMe",
+			wantField: "get https://api.telegram.org/bot[REDACTED]/getMe failed",
+		},
+		{
+			name:      "token of any bot",
+			msg:       "token is " + botToken,
+			field:     F("url", []byte("/bot"+botToken+"/sendMessage")),
+			want:      "token is [REDACTED]",
+			wantField: "/bot[REDACTED]/sendMessage",
+		},
+		{
+			name:      "personal data is kept",
+			msg:       "hi @alice",
+			field:     F("body", body),
+			want:      "hi @alice",
+			wantField: string(body),
+		},
+		{
+			name:     "personal fields of json",
+			personal: true,
+			msg:      "hi @alice",
+			field:    F("body", body),
+			want:     "hi @[REDACTED]",
+			wantField: `{"message":{"from":{"username":"[REDACTED]",` +
+				`"first_name":"[REDACTED]"},"text":"hi"}}`,
+		},
+		{name: "user", personal: true, msg: "joined", field: User("alice"), want: "joined",
+			wantField: "[REDACTED]"},
+		{name: "member", personal: true, msg: "joined", field: F("member", "alice"),
+			want: "joined", wantField: "[REDACTED]"},
+		{name: "user is kept", msg: "joined", field: User("alice"), want: "joined",
+			wantField: "alice"},
+		{name: "other fields are kept", personal: true, msg: "sent", field: ChatID(-100),
+			want: "sent", wantField: "-100"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, format := range []string{FormatJSON, FormatLogfmt} {
+				var buf bytes.Buffer
+				l, err := New(model.Log{Format: format, RedactPersonalData: tt.personal}, &buf,
+					"secret", "")
+				if err != nil {
+					t.Fatal(err)
+				}
+				l.Info(tt.msg, tt.field)
+				line := buf.String()
+				for _, leaked := range []string{"secret", botToken} {
+					if strings.Contains(line, leaked) {
+						t.Errorf("%s: %s is logged: %s", format, leaked, line)
+					}
+				}
+				if format != FormatJSON {
+					continue
+				}
+				var fields map[string]interface{}
+				if err := json.Unmarshal([]byte(line), &fields); err != nil {
+					t.Fatalf("%s isn't json: %s", line, err)
+				}
+				if fields["msg"] != tt.want {
+					t.Errorf("msg is %q, want %q", fields["msg"], tt.want)
+				}
+				if got := fmtValue(fields[tt.field.Key]); got != tt.wantField {
+					t.Errorf("%s is %s, want %s", tt.field.Key, got, tt.wantField)
+				}
+			}
+		})
+	}
+}
+
+// fmtValue format a value decoded from json as it was logged
+func fmtValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func TestRedactWith(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := New(model.Log{RedactPersonalData: true}, &buf, botToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.With(User("alice"), F("path", "/bot"+botToken)).Warn("failed")
+	want := `level=warn msg=failed user=[REDACTED] path=/bot[REDACTED]`
+	if got := buf.String(); !strings.Contains(got, want) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		level string
+		want  Level
+		err   bool
+	}{
+		{level: "", want: LevelInfo},
+		{level: "debug", want: LevelDebug},
+		{level: "WARN", want: LevelWarn},
+		{level: "error", want: LevelError},
+		{level: "fatal", want: LevelInfo, err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.level)
+		if got != tt.want || (err != nil) != tt.err {
+			t.Errorf("%q: got %s and error %v, want %s and error %v", tt.level, got, err,
+				tt.want, tt.err)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  model.Log
+		err  bool
+	}{
+		{name: "default"},
+		{name: "json", cfg: model.Log{Level: "debug", Format: "JSON"}},
+		{name: "unknown level", cfg: model.Log{Level: "trace"}, err: true},
+		{name: "unknown format", cfg: model.Log{Format: "xml"}, err: true},
+	}
+	for _, tt := range tests {
+		if _, err := New(tt.cfg, &bytes.Buffer{}); (err != nil) != tt.err {
+			t.Errorf("%s: error is %v, want error %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestLog(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    model.Log
+		log    func(l Logger)
+		want   []string
+		absent []string
+	}{
+		{
+			name: "logfmt",
+			log: func(l Logger) {
+				l.With(Task("remind_task")).Info("update is comming", UpdateID(7),
+					F("text", `say "hi"`), F("empty", ""))
+			},
+			want: []string{`level=info msg="update is comming" task=remind_task update_id=7 ` +
+				`text="say \"hi\"" empty=""`},
+		},
+		{
+			name: "json",
+			cfg:  model.Log{Format: FormatJSON},
+			log: func(l Logger) {
+				l.Error("reply failed", ChatID(-100), Err(errors.New("timeout")),
+					F("ids", []int{1, 2}))
+			},
+			want: []string{`"level":"error","msg":"reply failed","chat_id":-100,` +
+				`"error":"timeout","ids":[1,2]}`},
+		},
+		{
+			name: "messages below the level aren't logged",
+			cfg:  model.Log{Level: "warn"},
+			log: func(l Logger) {
+				l.Debug("raw body")
+				l.Info("update is comming")
+				l.Warn("queue is full")
+			},
+			want:   []string{"msg=\"queue is full\""},
+			absent: []string{"raw body", "update is comming"},
+		},
+		{
+			name: "with doesn't change the parent",
+			log: func(l Logger) {
+				l.With(User("alice")).Info("joined")
+				l.Info("started")
+			},
+			want:   []string{"msg=joined user=alice", "msg=started\n"},
+			absent: []string{"msg=started user"},
+		},
+		{
+			name: "standard log",
+			log: func(l Logger) {
+				NewWriter(l, LevelWarn).Write([]byte("http: TLS handshake error\nsecond\n"))
+			},
+			want: []string{`level=warn msg="http: TLS handshake error"`, "level=warn msg=second"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l, err := New(tt.cfg, &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.log(l)
+			got := buf.String()
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("%s doesn't contain %s", got, want)
+				}
+			}
+			for _, absent := range tt.absent {
+				if strings.Contains(got, absent) {
+					t.Errorf("%s contains %s", got, absent)
+				}
+			}
+		})
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	l, err := New(model.Log{Level: "warn"}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Enabled(LevelInfo) || !l.Enabled(LevelWarn) || !l.With(Task("a")).Enabled(LevelError) {
+		t.Error("only warn and error are enabled")
+	}
+}
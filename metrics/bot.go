@@ -0,0 +1,45 @@
+package metrics
+
+var (
+	// UpdatesReceived count updates received by webhook
+	UpdatesReceived = NewCounter("tgbot_updates_received_total",
+		"Number of updates received from Telegram webhook.")
+	// UpdatesDropped count updates dropped by middlewares by reason
+	UpdatesDropped = NewCounter("tgbot_updates_dropped_total",
+		"Number of updates dropped before handling.", "reason")
+	// UpdatePanics count panics recovered while handling updates
+	UpdatePanics = NewCounter("tgbot_update_panics_total",
+		"Number of panics recovered while handling updates.")
+	// UpdateDuration observe how long it takes to handle an update
+	UpdateDuration = NewHistogram("tgbot_update_duration_seconds",
+		"Duration of handling updates.", DefaultBuckets)
+	// CommandsDispatched count dispatched commands by command name
+	CommandsDispatched = NewCounter("tgbot_commands_dispatched_total",
+		"Number of dispatched bot commands.", "command")
+	// ValidationRejections count commands rejected by validator
+	ValidationRejections = NewCounter("tgbot_validation_rejections_total",
+		"Number of commands rejected by validators.", "validator")
+	// CheckInsRecorded count check-ins recorded in repo
+	CheckInsRecorded = NewCounter("tgbot_checkins_recorded_total",
+		"Number of check-ins recorded.")
+	// RemindersSent count reminders sent to channels
+	RemindersSent = NewCounter("tgbot_reminders_sent_total",
+		"Number of reminders sent.")
+	// RemindersFailed count reminders failed to send
+	RemindersFailed = NewCounter("tgbot_reminders_failed_total",
+		"Number of reminders failed to send.")
+	// TelegramAPIDuration observe latency of Telegram bot API by method
+	TelegramAPIDuration = NewHistogram("tgbot_telegram_api_duration_seconds",
+		"Latency of Telegram bot API requests.", DefaultBuckets, "method", "result")
+	// TaskRunDuration observe duration of each run of a task
+	TaskRunDuration = NewHistogram("tgbot_task_run_duration_seconds",
+		"Duration of task runs.", DefaultBuckets, "task")
+)
+
+// Result return the value of `result` label according to `err`
+func Result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
@@ -0,0 +1,252 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type (
+	// Counter is a cumulative metric which only increase
+	Counter struct {
+		desc
+		sync.Mutex
+		values map[string]*counterValue
+	}
+
+	counterValue struct {
+		labelValues []string
+		value       float64
+	}
+
+	// Histogram samples observations and counts them in buckets
+	Histogram struct {
+		desc
+		sync.Mutex
+		buckets []float64
+		values  map[string]*histogramValue
+	}
+
+	histogramValue struct {
+		labelValues []string
+		counts      []uint64
+		sum         float64
+		count       uint64
+	}
+
+	desc struct {
+		name       string
+		help       string
+		labelNames []string
+	}
+
+	collector interface {
+		write(w *bufio.Writer)
+	}
+
+	registry struct {
+		sync.Mutex
+		collectors []collector
+	}
+)
+
+var (
+	// DefaultBuckets is suitable for latency of remote request in seconds
+	DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+	defaultRegistry = &registry{}
+)
+
+// NewCounter return a Counter registered to default registry
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{
+		desc:   desc{name, help, labelNames},
+		values: map[string]*counterValue{},
+	}
+	defaultRegistry.register(c)
+	return c
+}
+
+// NewHistogram return a Histogram registered to default registry, `buckets`
+// are upper bounds in increasing order
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	h := &Histogram{
+		desc:    desc{name, help, labelNames},
+		buckets: buckets,
+		values:  map[string]*histogramValue{},
+	}
+	defaultRegistry.register(h)
+	return h
+}
+
+// Inc increase the counter of `labelValues` by 1
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increase the counter of `labelValues` by `v`
+func (c *Counter) Add(v float64, labelValues ...string) {
+	key := c.key(labelValues)
+	c.Lock()
+	defer c.Unlock()
+	value, ok := c.values[key]
+	if !ok {
+		value = &counterValue{labelValues: labelValues}
+		c.values[key] = value
+	}
+	value.value += v
+}
+
+// Value return current value of the counter of `labelValues`
+func (c *Counter) Value(labelValues ...string) float64 {
+	c.Lock()
+	defer c.Unlock()
+	if value, ok := c.values[c.key(labelValues)]; ok {
+		return value.value
+	}
+	return 0
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.Lock()
+	defer c.Unlock()
+	c.writeHeader(w, "counter")
+	if len(c.labelNames) == 0 && len(c.values) == 0 {
+		fmt.Fprintf(w, "%s 0\n", c.name)
+	}
+	for _, key := range sortedKeys(c.values) {
+		value := c.values[key]
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labels(value.labelValues, "", ""),
+			formatFloat(value.value))
+	}
+}
+
+// Observe add a observation `v` to the histogram of `labelValues`
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	key := h.key(labelValues)
+	h.Lock()
+	defer h.Unlock()
+	value, ok := h.values[key]
+	if !ok {
+		value = &histogramValue{
+			labelValues: labelValues,
+			counts:      make([]uint64, len(h.buckets)),
+		}
+		h.values[key] = value
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			value.counts[i]++
+		}
+	}
+	value.sum += v
+	value.count++
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.Lock()
+	defer h.Unlock()
+	h.writeHeader(w, "histogram")
+	for _, key := range sortedKeys(h.values) {
+		value := h.values[key]
+		for i, upper := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name,
+				h.labels(value.labelValues, "le", formatFloat(upper)), value.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name,
+			h.labels(value.labelValues, "le", "+Inf"), value.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labels(value.labelValues, "", ""),
+			formatFloat(value.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labels(value.labelValues, "", ""),
+			value.count)
+	}
+}
+
+func (d desc) key(labelValues []string) string {
+	if len(labelValues) != len(d.labelNames) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d",
+			d.name, len(d.labelNames), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+func (d desc) writeHeader(w *bufio.Writer, kind string) {
+	help := strings.Replace(d.help, `\`, `\\`, -1)
+	help = strings.Replace(help, "\n", `\n`, -1)
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, kind)
+}
+
+// labels return `{name="value",...}`, `extraName` is appended if it isn't
+// empty
+func (d desc) labels(labelValues []string, extraName, extraValue string) string {
+	pairs := make([]string, 0, len(labelValues)+1)
+	for i, name := range d.labelNames {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", name, quote(labelValues[i])))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", extraName, quote(extraValue)))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (r *registry) register(c collector) {
+	r.Lock()
+	defer r.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+func (r *registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	r.Lock()
+	for _, c := range r.collectors {
+		c.write(bw)
+	}
+	r.Unlock()
+	bw.Flush()
+}
+
+// Handler return a http.Handler which expose all metrics in Prometheus
+// text format
+func Handler() http.Handler {
+	return defaultRegistry
+}
+
+func quote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, "\n", `\n`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	return `"` + s + `"`
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch values := m.(type) {
+	case map[string]*counterValue:
+		for k := range values {
+			keys = append(keys, k)
+		}
+	case map[string]*histogramValue:
+		for k := range values {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
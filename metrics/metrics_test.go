@@ -0,0 +1,177 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestCounter(name, help string, labelNames ...string) *Counter {
+	return &Counter{desc: desc{name, help, labelNames}, values: map[string]*counterValue{}}
+}
+
+func newTestHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	return &Histogram{desc: desc{name, help, labelNames}, buckets: buckets,
+		values: map[string]*histogramValue{}}
+}
+
+func output(c collector) string {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	c.write(w)
+	w.Flush()
+	return buf.String()
+}
+
+func TestCounterWrite(t *testing.T) {
+	tests := []struct {
+		name       string
+		labelNames []string
+		add        func(c *Counter)
+		want       string
+	}{
+		{
+			name: "without labels and values",
+			want: "# HELP test_total help\n# TYPE test_total counter\ntest_total 0\n",
+		},
+		{
+			name: "without labels",
+			add: func(c *Counter) {
+				c.Inc()
+				c.Add(1.5)
+			},
+			want: "# HELP test_total help\n# TYPE test_total counter\ntest_total 2.5\n",
+		},
+		{
+			name:       "labels without values",
+			labelNames: []string{"command"},
+			want:       "# HELP test_total help\n# TYPE test_total counter\n",
+		},
+		{
+			name:       "labels are sorted",
+			labelNames: []string{"command", "result"},
+			add: func(c *Counter) {
+				c.Inc("/start", "ok")
+				c.Inc("/checkin", "ok")
+				c.Inc("/checkin", "ok")
+			},
+			want: "# HELP test_total help\n# TYPE test_total counter\n" +
+				`test_total{command="/checkin",result="ok"} 2` + "\n" +
+				`test_total{command="/start",result="ok"} 1` + "\n",
+		},
+		{
+			name:       "label values are escaped",
+			labelNames: []string{"path"},
+			add: func(c *Counter) {
+				c.Inc(`C:\dir "a"` + "\nb")
+			},
+			want: "# HELP test_total help\n# TYPE test_total counter\n" +
+				`test_total{path="C:\\dir \"a\"\nb"} 1` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCounter("test_total", "help", tt.labelNames...)
+			if tt.add != nil {
+				tt.add(c)
+			}
+			if got := output(c); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistogramWrite(t *testing.T) {
+	tests := []struct {
+		name         string
+		labelNames   []string
+		observations []float64
+		labelValues  []string
+		want         string
+	}{
+		{
+			name:         "without labels",
+			observations: []float64{0.05, 0.1, 0.3, 2},
+			want: "# HELP test_seconds help\n# TYPE test_seconds histogram\n" +
+				`test_seconds_bucket{le="0.1"} 2` + "\n" +
+				`test_seconds_bucket{le="1"} 3` + "\n" +
+				`test_seconds_bucket{le="+Inf"} 4` + "\n" +
+				"test_seconds_sum 2.45\n" +
+				"test_seconds_count 4\n",
+		},
+		{
+			name:         "with labels",
+			labelNames:   []string{"method"},
+			labelValues:  []string{`send"Message`},
+			observations: []float64{1},
+			want: "# HELP test_seconds help\n# TYPE test_seconds histogram\n" +
+				`test_seconds_bucket{method="send\"Message",le="0.1"} 0` + "\n" +
+				`test_seconds_bucket{method="send\"Message",le="1"} 1` + "\n" +
+				`test_seconds_bucket{method="send\"Message",le="+Inf"} 1` + "\n" +
+				`test_seconds_sum{method="send\"Message"} 1` + "\n" +
+				`test_seconds_count{method="send\"Message"} 1` + "\n",
+		},
+		{
+			name:         "observations beyond the last bucket",
+			observations: []float64{5, math.Inf(1)},
+			want: "# HELP test_seconds help\n# TYPE test_seconds histogram\n" +
+				`test_seconds_bucket{le="0.1"} 0` + "\n" +
+				`test_seconds_bucket{le="1"} 0` + "\n" +
+				`test_seconds_bucket{le="+Inf"} 2` + "\n" +
+				"test_seconds_sum +Inf\n" +
+				"test_seconds_count 2\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHistogram("test_seconds", "help", []float64{0.1, 1}, tt.labelNames...)
+			for _, v := range tt.observations {
+				h.Observe(v, tt.labelValues...)
+			}
+			if got := output(h); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHelpIsEscaped(t *testing.T) {
+	c := newTestCounter("test_total", `a\b`+"\nc")
+	want := "# HELP test_total a\\\\b\\nc\n# TYPE test_total counter\ntest_total 0\n"
+	if got := output(c); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestLabelValuesMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expect panic if number of label values is wrong")
+		}
+	}()
+	newTestCounter("test_total", "help", "command").Inc()
+}
+
+func TestRegistryServeHTTP(t *testing.T) {
+	c := newTestCounter("test_total", "help")
+	c.Inc()
+	h := newTestHistogram("test_seconds", "help", []float64{1})
+	h.Observe(0.5)
+	r := &registry{}
+	r.register(c)
+	r.register(h)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("content type is %s", got)
+	}
+	want := output(c) + output(h)
+	if got := rec.Body.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 type (
 	// From is a struct hold information of message where came from
 	From struct {
@@ -28,8 +30,18 @@ type (
 
 	// TgMessage represent message recieved from Telegram
 	TgMessage struct {
-		UpdateID int     `json:"update_id"`
-		Message  Message `json:"message"`
+		UpdateID      int           `json:"update_id"`
+		Message       Message       `json:"message"`
+		CallbackQuery CallbackQuery `json:"callback_query"`
+	}
+
+	// CallbackQuery is sent by Telegram when a button of inline keyboard
+	// was pressed
+	CallbackQuery struct {
+		ID      string  `json:"id"`
+		From    From    `json:"from"`
+		Message Message `json:"message"`
+		Data    string  `json:"data"`
 	}
 
 	// Message contains detail message information sent from Telegram
@@ -40,13 +52,67 @@ type (
 		Entities  []Entity `json:"entities"`
 		Date      int      `json:"date"`
 		Text      string   `json:"text"`
+		// Caption and CaptionEntities is text of a photo
+		Caption         string      `json:"caption"`
+		CaptionEntities []Entity    `json:"caption_entities"`
+		Photo           []PhotoSize `json:"photo"`
+		Location        *Location   `json:"location"`
+		ReplyToMessage  *Message    `json:"reply_to_message"`
+	}
+
+	// PhotoSize is one size of a photo sent to Telegram
+	PhotoSize struct {
+		FileID string `json:"file_id"`
+		Width  int    `json:"width"`
+		Height int    `json:"height"`
+	}
+
+	// Location is a point on the map shared by user
+	Location struct {
+		Latitude  float64 `json:"latitude"`
+		Longitude float64 `json:"longitude"`
 	}
 
 	// BotMessage represent message send by bot
 	BotMessage struct {
 		//
-		ChatID int64  `json:"chat_id"`
-		Text   string `json:"text"`
+		ChatID      int64                 `json:"chat_id"`
+		Text        string                `json:"text"`
+		ReplyMarkup *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+	}
+
+	// InlineKeyboardMarkup is buttons attached to a message
+	InlineKeyboardMarkup struct {
+		InlineKeyboard [][]InlineKeyboardButton `json:"inline_keyboard"`
+	}
+
+	// InlineKeyboardButton is a button which send CallbackQuery with
+	// CallbackData to bot when it's pressed
+	InlineKeyboardButton struct {
+		Text         string `json:"text"`
+		CallbackData string `json:"callback_data"`
+	}
+
+	// EditMessage replace text of a message sent by bot, the inline
+	// keyboard is removed if ReplyMarkup is nil
+	EditMessage struct {
+		ChatID      int64                 `json:"chat_id"`
+		MessageID   int                   `json:"message_id"`
+		Text        string                `json:"text"`
+		ReplyMarkup *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+	}
+
+	// CallbackAnswer answer a CallbackQuery, Text is shown as a
+	// notification to the user who pressed the button
+	CallbackAnswer struct {
+		CallbackQueryID string `json:"callback_query_id"`
+		Text            string `json:"text,omitempty"`
+	}
+
+	// BotCommand represent a command shown in the command menu of bot
+	BotCommand struct {
+		Command     string `json:"command"`
+		Description string `json:"description"`
 	}
 
 	// ReplyMessage represent message sent by bot
@@ -55,6 +121,28 @@ type (
 		ReplyToMessageID int `json:"reply_to_message_id"`
 	}
 
+	// DocumentMessage represent a file sent by bot
+	DocumentMessage struct {
+		ChatID           int64
+		ReplyToMessageID int
+		Caption          string
+		FileName         string
+		Content          []byte
+	}
+
+	// StickerMessage send a sticker by file id or URL
+	StickerMessage struct {
+		ChatID  int64  `json:"chat_id"`
+		Sticker string `json:"sticker"`
+	}
+
+	// AnimationMessage send a GIF by file id or URL with Caption
+	AnimationMessage struct {
+		ChatID    int64  `json:"chat_id"`
+		Animation string `json:"animation"`
+		Caption   string `json:"caption,omitempty"`
+	}
+
 	// TimeRange contain a period of time
 	TimeRange struct {
 		Begin string `json:"begin"`
@@ -65,21 +153,325 @@ type (
 	Remind struct {
 		RemindInterval string    `json:"remind_interval"`
 		TimeRange      TimeRange `json:"time_range"`
+		// Levels are pools of reminder messages by escalation level, the
+		// n-th reminder of a day to a user is chosen from the n-th pool, the
+		// last pool is used after that
+		Levels [][]ReminderMessage `json:"levels"`
+		// Rotation is how a message is chosen from a pool, RotationRandom
+		// or RotationRoundRobin, default is RotationRandom
+		Rotation string `json:"rotation"`
+	}
+
+	// ReminderMessage is a message of reminder pools, Text is a template
+	// like `reminder` of templates, a sticker is sent before Text, and Text
+	// is the caption of an animation
+	ReminderMessage struct {
+		Text      string `json:"text"`
+		Sticker   string `json:"sticker"`
+		Animation string `json:"animation"`
+	}
+
+	// Policy classify a check in by its time, check in is allowed from
+	// begin of TimeRange, it's on time until OnTime plus Grace, late until
+	// Late, and rejected after Late. Times are formatted like TimeRange
+	Policy struct {
+		// OnTime is the on time cutoff, default is Late
+		OnTime string `json:"on_time"`
+		// Grace is a duration after OnTime which is still on time, e.g. "5m"
+		Grace string `json:"grace"`
+		// Late is the end of late window, default is end of TimeRange
+		Late string `json:"late"`
+	}
+	// TLS contains TLS settings used by the http client
+	TLS struct {
+		// InsecureSkipVerify disable verification of server certificate
+		InsecureSkipVerify bool `json:"insecure_skip_verify"`
+		// CAFile is a PEM file which contains extra root certificates
+		CAFile string `json:"ca_file"`
+		// CertFile and KeyFile is a client certificate pair
+		CertFile string `json:"cert_file"`
+		KeyFile  string `json:"key_file"`
+	}
+
+	// HTTPClient contains configuration of the http client which send
+	// request to Telegram and calendar service
+	HTTPClient struct {
+		// Timeout limit the whole request, e.g. "30s"
+		Timeout string `json:"timeout"`
+		// DialTimeout limit establishing connection, e.g. "10s"
+		DialTimeout string `json:"dial_timeout"`
+		// ProxyURL is a http, https or socks5 proxy url, environment
+		// variable HTTPS_PROXY, HTTP_PROXY was used if it's empty
+		ProxyURL string `json:"proxy_url"`
+		TLS      TLS    `json:"tls"`
+	}
+
+	// API contains configuration of the REST API
+	API struct {
+		// Tokens is accepted by `Authorization: Bearer <token>` header, the
+		// API is disabled if it's empty
+		Tokens []string `json:"tokens"`
+	}
+
+	// RateLimit limit how many updates of a user are handled
+	RateLimit struct {
+		// Updates is max number of updates of a user in Interval, default
+		// is 20, the limit is disabled if it's negative
+		Updates int `json:"updates"`
+		// Interval is length of the window, default is "1m"
+		Interval string `json:"interval"`
+	}
+
+	// WorkerPool contains configuration of workers which handle updates
+	WorkerPool struct {
+		// Workers is number of workers, default is 4
+		Workers int `json:"workers"`
+		// QueueSize is max number of updates waiting for workers, default
+		// is 100
+		QueueSize int `json:"queue_size"`
+	}
+
+	// UserSettings is personal settings of a user changed in private chat
+	// with the bot
+	UserSettings struct {
+		// ChatID is the private chat with the bot, it's 0 if the user
+		// hasn't sent `/start`
+		ChatID int64 `json:"chat_id,omitempty"`
+		// RemindDM send reminders to the private chat as well
+		RemindDM bool `json:"remind_dm"`
+		// Language is the preferred language of replies, e.g. "zh-CN"
+		Language string `json:"language,omitempty"`
+		// QuietDays are weekdays without reminders
+		QuietDays []time.Weekday `json:"quiet_days,omitempty"`
 	}
+
+	// CheckIn represent a check in record
+	CheckIn struct {
+		// ID is `yyyymmdd-user` which unique identify a check in of a group
+		ID    string    `json:"id"`
+		User  string    `json:"user"`
+		Time  time.Time `json:"time"`
+		Group string    `json:"group,omitempty"`
+		// Kind is CheckInOffice, CheckInRemote or empty if it's unknown
+		Kind string `json:"kind,omitempty"`
+		// Status is CheckInOnTime, CheckInLate or empty if it's unknown
+		Status string `json:"status,omitempty"`
+		Note   string `json:"note,omitempty"`
+		// Location and Photo is proof of the check in, Photo is file id of
+		// Telegram
+		Location *Location `json:"location,omitempty"`
+		Photo    string    `json:"photo,omitempty"`
+	}
+
+	// CheckInQuery is condition of querying check in records, zero value of
+	// each field matches all records
+	CheckInQuery struct {
+		User string
+		// From and To is the date range, both are inclusive
+		From time.Time
+		To   time.Time
+	}
+
+	// AuditEntry record who changed a check in, when, what and why
+	AuditEntry struct {
+		Time time.Time `json:"time"`
+		// Actor is user name of the admin, or `api` if it's changed by API
+		Actor string `json:"actor"`
+		// Action is AuditRecord, AuditAmend or AuditRevoke
+		Action    string `json:"action"`
+		User      string `json:"user"`
+		CheckInID string `json:"checkin_id"`
+		// Before and After is the check in before and after the change
+		Before *CheckIn `json:"before,omitempty"`
+		After  *CheckIn `json:"after,omitempty"`
+		Reason string   `json:"reason"`
+	}
+
+	// Log contains logging configuration
+	Log struct {
+		// Level is one of debug, info, warn and error, default is info
+		Level string `json:"level"`
+		// Format is logfmt or json, default is logfmt
+		Format string `json:"format"`
+		// RedactPersonalData replace user names in log with [REDACTED]
+		RedactPersonalData bool `json:"redact_personal_data"`
+	}
+
+	// Group represent a team which checks in in its own chats, empty field
+	// inherits the value of Config
+	Group struct {
+		// Name unique identify a group, it's used in task names, API and
+		// the directory where check ins of the group are stored
+		Name                      string   `json:"name"`
+		Channels                  []int64  `json:"channels"`
+		CheckUsers                []string `json:"check_users"`
+		Admins                    []string `json:"admins"`
+		Remind                    Remind   `json:"remind"`
+		Policy                    Policy   `json:"policy"`
+		CNCalendarServiceEndpoint string   `json:"cn_calendar_service_endpoint"`
+		// JoinApproval override the top level one if it's set
+		JoinApproval *bool `json:"join_approval"`
+		// Language is the language of messages posted to channels of the
+		// group
+		Language string `json:"language"`
+		// Templates override templates of the top level configuration by
+		// kind
+		Templates map[string]string `json:"templates"`
+	}
+
 	// Config represent global configuration
 	Config struct {
-		Name            string   `json:"name"`
-		TgbotToken      string   `json:"tgbot_token"`
-		ListenAddr      string   `json:"listen_addr"`
-		CheckUesrs      []string `json:"check_users"`
-		WebhookEndpoint string   `json:"webhook_endpoint"`
-		Channels        []int64  `json:"channels"`
-		Remind          Remind   `json:"remind"`
+		Name            string     `json:"name"`
+		TgbotToken      string     `json:"tgbot_token"`
+		ListenAddr      string     `json:"listen_addr"`
+		CheckUesrs      []string   `json:"check_users"`
+		WebhookEndpoint string     `json:"webhook_endpoint"`
+		Channels        []int64    `json:"channels"`
+		Remind          Remind     `json:"remind"`
+		Policy          Policy     `json:"policy"`
+		HTTPClient      HTTPClient `json:"http_client"`
+		Log             Log        `json:"log"`
+		API             API        `json:"api"`
+		RateLimit       RateLimit  `json:"rate_limit"`
+		WorkerPool      WorkerPool `json:"worker_pool"`
+		// Admins are users who can export data by bot command
+		Admins []string `json:"admins"`
+		// JoinApproval require `/join` to be approved by an admin
+		JoinApproval bool `json:"join_approval"`
+		// CalendarOptional keep the bot ready when the calendar wasn't
+		// looked up recently, every day is a work day without it
+		CalendarOptional bool `json:"calendar_optional"`
+		// DataDir is where check in history is stored, it can be overridden
+		// by `--data-dir` flag or TGBOT_DATA_DIR environment variable
+		DataDir string `json:"data_dir"`
 		//
 		CNCalendarServiceEndpoint string `json:"cn_calendar_service_endpoint"`
+		// Language is the language of messages posted to channels, e.g.
+		// reminders, it's the default language if it's empty
+		Language string `json:"language"`
+		// Messages override builtin messages of the bot by language and key,
+		// e.g. {"en": {"checked_in": "Got it @%s"}}
+		Messages map[string]map[string]string `json:"messages"`
+		// Templates replace messages of some kinds with `text/template`
+		// templates by kind, e.g. {"reminder": "Hi @{{.User}}"}
+		Templates map[string]string `json:"templates"`
+		// Groups is teams with their own members and rules, the top level
+		// configuration is the only group if it's empty
+		Groups []Group `json:"groups"`
+		// Group is the name of group which the configuration is applied to,
+		// it's set by ForGroup
+		Group string `json:"-"`
 	}
 )
 
+const (
+	// CheckInOffice is kind of check in at office
+	CheckInOffice = "office"
+	// CheckInRemote is kind of check in working from home
+	CheckInRemote = "wfh"
+
+	// CheckInOnTime is status of check in before on time cutoff
+	CheckInOnTime = "on_time"
+	// CheckInLate is status of check in in the late window
+	CheckInLate = "late"
+
+	// RotationRandom choose a reminder message randomly
+	RotationRandom = "random"
+	// RotationRoundRobin choose reminder messages in turn
+	RotationRoundRobin = "round_robin"
+
+	// AuditRecord is action of adding a check in
+	AuditRecord = "record"
+	// AuditAmend is action of changing a check in
+	AuditAmend = "amend"
+	// AuditRevoke is action of removing a check in
+	AuditRevoke = "revoke"
+)
+
+// DefaultGroup is the name of group which is made from the top level
+// configuration when no group is configured
+const DefaultGroup = "default"
+
+// AllGroups return configured groups, or a group made from the top level
+// configuration if no group is configured
+func (c Config) AllGroups() []Group {
+	if len(c.Groups) > 0 {
+		return c.Groups
+	}
+	return []Group{{
+		Name:                      DefaultGroup,
+		Channels:                  c.Channels,
+		CheckUsers:                c.CheckUesrs,
+		Admins:                    c.Admins,
+		Remind:                    c.Remind,
+		Policy:                    c.Policy,
+		CNCalendarServiceEndpoint: c.CNCalendarServiceEndpoint,
+		JoinApproval:              &c.JoinApproval,
+		Language:                  c.Language,
+	}}
+}
+
+// ForGroup return the configuration applied to group `g`, fields set by `g`
+// override the top level ones, fields of `g` which aren't set inherit them,
+// a list set to `[]` is set
+func (c Config) ForGroup(g Group) Config {
+	c.Group = g.Name
+	c.Groups = nil
+	if g.Channels != nil {
+		c.Channels = g.Channels
+	}
+	if g.CheckUsers != nil {
+		c.CheckUesrs = g.CheckUsers
+	}
+	if g.Admins != nil {
+		c.Admins = g.Admins
+	}
+	if g.Remind.RemindInterval != "" {
+		c.Remind.RemindInterval = g.Remind.RemindInterval
+	}
+	if g.Remind.TimeRange.Begin != "" {
+		c.Remind.TimeRange.Begin = g.Remind.TimeRange.Begin
+	}
+	if g.Remind.TimeRange.End != "" {
+		c.Remind.TimeRange.End = g.Remind.TimeRange.End
+	}
+	if len(g.Remind.Levels) > 0 {
+		c.Remind.Levels = g.Remind.Levels
+	}
+	if g.Remind.Rotation != "" {
+		c.Remind.Rotation = g.Remind.Rotation
+	}
+	if g.Policy.OnTime != "" {
+		c.Policy.OnTime = g.Policy.OnTime
+	}
+	if g.Policy.Grace != "" {
+		c.Policy.Grace = g.Policy.Grace
+	}
+	if g.Policy.Late != "" {
+		c.Policy.Late = g.Policy.Late
+	}
+	if g.CNCalendarServiceEndpoint != "" {
+		c.CNCalendarServiceEndpoint = g.CNCalendarServiceEndpoint
+	}
+	if g.JoinApproval != nil {
+		c.JoinApproval = *g.JoinApproval
+	}
+	if g.Language != "" {
+		c.Language = g.Language
+	}
+	if len(g.Templates) > 0 {
+		templates := map[string]string{}
+		for kind, text := range c.Templates {
+			templates[kind] = text
+		}
+		for kind, text := range g.Templates {
+			templates[kind] = text
+		}
+		c.Templates = templates
+	}
+	return c
+}
+
 // TextInfo tell user a BotMessage is a common Text interface
 func (b BotMessage) TextInfo() string {
 	return b.Text
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestForGroup(t *testing.T) {
+	top := Config{
+		Channels:   []int64{1},
+		CheckUesrs: []string{"alice"},
+		Admins:     []string{"boss"},
+		Remind: Remind{
+			RemindInterval: "5m",
+			TimeRange:      TimeRange{Begin: "08:00:00+08:00", End: "10:00:00+08:00"},
+			Rotation:       RotationRandom,
+		},
+		Policy:                    Policy{OnTime: "09:00:00+08:00", Grace: "5m"},
+		CNCalendarServiceEndpoint: "http://calendar",
+		JoinApproval:              true,
+		Language:                  "en",
+		Templates:                 map[string]string{"reminder": "top", "checked_in": "top"},
+		Groups:                    []Group{{Name: "ops"}},
+	}
+	tests := []struct {
+		name  string
+		group string
+		want  func(c *Config)
+	}{
+		{name: "nothing is set", group: `{"name": "ops"}`},
+		{
+			name:  "lists are set",
+			group: `{"name": "ops", "channels": [2], "check_users": ["bob"], "admins": ["lead"]}`,
+			want: func(c *Config) {
+				c.Channels = []int64{2}
+				c.CheckUesrs = []string{"bob"}
+				c.Admins = []string{"lead"}
+			},
+		},
+		{
+			name:  "empty lists are set",
+			group: `{"name": "ops", "channels": [], "check_users": [], "admins": []}`,
+			want: func(c *Config) {
+				c.Channels = []int64{}
+				c.CheckUesrs = []string{}
+				c.Admins = []string{}
+			},
+		},
+		{
+			name: "fields of remind and policy are set separately",
+			group: `{"name": "ops", "remind": {"time_range": {"end": "11:00:00+08:00"}},
+				"policy": {"late": "12:00:00+08:00"}}`,
+			want: func(c *Config) {
+				c.Remind.TimeRange.End = "11:00:00+08:00"
+				c.Policy.Late = "12:00:00+08:00"
+			},
+		},
+		{
+			name:  "join approval is turned off",
+			group: `{"name": "ops", "join_approval": false}`,
+			want:  func(c *Config) { c.JoinApproval = false },
+		},
+		{
+			name: "calendar and language are set",
+			group: `{"name": "ops", "cn_calendar_service_endpoint": "http://another",
+				"language": "zh-hans"}`,
+			want: func(c *Config) {
+				c.CNCalendarServiceEndpoint = "http://another"
+				c.Language = "zh-hans"
+			},
+		},
+		{
+			name:  "templates are merged",
+			group: `{"name": "ops", "templates": {"reminder": "ops", "not_tracked": "ops"}}`,
+			want: func(c *Config) {
+				c.Templates = map[string]string{"reminder": "ops", "checked_in": "top",
+					"not_tracked": "ops"}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var g Group
+			if err := json.Unmarshal([]byte(tt.group), &g); err != nil {
+				t.Fatal(err)
+			}
+			want := top
+			want.Group = "ops"
+			want.Groups = nil
+			if tt.want != nil {
+				tt.want(&want)
+			}
+			if got := top.ForGroup(g); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+	if top.Templates["reminder"] != "top" {
+		t.Error("templates of the top level configuration are changed")
+	}
+}
+
+func TestAllGroups(t *testing.T) {
+	c := Config{CheckUesrs: []string{"alice"}, Admins: []string{"boss"}, Language: "en"}
+	groups := c.AllGroups()
+	if len(groups) != 1 || groups[0].Name != DefaultGroup {
+		t.Fatalf("got %+v, want group %s", groups, DefaultGroup)
+	}
+	if got := c.ForGroup(groups[0]); !reflect.DeepEqual(got.CheckUesrs, c.CheckUesrs) ||
+		!reflect.DeepEqual(got.Admins, c.Admins) || got.Language != c.Language {
+		t.Errorf("got %+v, want the top level configuration", got)
+	}
+
+	c.Groups = []Group{{Name: "ops"}}
+	if groups := c.AllGroups(); len(groups) != 1 || groups[0].Name != "ops" {
+		t.Errorf("got %+v, want configured groups", groups)
+	}
+}
@@ -0,0 +1,99 @@
+package repo
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/zhao-kun/reminder-tgbot/logging"
+	"github.com/zhao-kun/reminder-tgbot/model"
+	"github.com/zhao-kun/reminder-tgbot/util"
+)
+
+// auditFileName is the name of the append-only audit log, each line is a
+// json of model.AuditEntry
+const auditFileName = "audit.log"
+
+// auditLock serialize writing audit log of all groups
+var auditLock sync.Mutex
+
+func (r repo) Amend(checkIn model.CheckIn) error {
+	if err := validateCheckIn(checkIn); err != nil {
+		return err
+	}
+	if _, err := r.Get(checkIn.ID); err != nil {
+		return err
+	}
+	checkIn.Group = r.cfg.Group
+	checkIn.Time = util.GetChinaTimeFromUnix(checkIn.Time.Unix())
+	checkTime, user, _ := parseCheckInID(checkIn.ID)
+	if checkIn.User != user || util.GetDate(checkIn.Time) != util.GetDate(checkTime) {
+		return fmt.Errorf("user and date of check in %s can't be changed", checkIn.ID)
+	}
+
+	content, err := json.Marshal(checkIn)
+	if err != nil {
+		return err
+	}
+	_, file := r.checkInFilePath(checkIn.Time, checkIn.User)
+	return writeFileAtomic(file, content)
+}
+
+func (r repo) Audit(entry model.AuditEntry) error {
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	auditLock.Lock()
+	defer auditLock.Unlock()
+	h, err := os.OpenFile(r.auditFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("open audit log error %s", err)
+	}
+	defer h.Close()
+	if _, err := h.Write(append(content, '\n')); err != nil {
+		return fmt.Errorf("write audit log error %s", err)
+	}
+	return h.Sync()
+}
+
+func (r repo) AuditLog(user string, limit int) ([]model.AuditEntry, error) {
+	h, err := os.Open(r.auditFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer h.Close()
+
+	var entries []model.AuditEntry
+	scanner := bufio.NewScanner(h)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry model.AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			r.l.Warn("skip malformed audit entry", logging.Err(err))
+			continue
+		}
+		if user != "" && entry.User != user {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// newest first
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
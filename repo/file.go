@@ -0,0 +1,42 @@
+package repo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic replace `file` with `content`, it's written to a temporary
+// file in the same directory and synced before renamed to `file`, so `file`
+// isn't broken if the bot crashed while writing
+func writeFileAtomic(file string, content []byte) error {
+	dir := filepath.Dir(file)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create %s error %s", dir, err)
+	}
+	h, err := ioutil.TempFile(dir, filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create temporary file of %s error %s", file, err)
+	}
+	tmp := h.Name()
+	_, err = h.Write(content)
+	if err == nil {
+		err = h.Sync()
+	}
+	if closeErr := h.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, 0600)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write %s error %s", tmp, err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rename %s error %s", tmp, err)
+	}
+	return nil
+}
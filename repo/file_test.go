@@ -0,0 +1,62 @@
+package repo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "a", "b", "members.json")
+
+	for _, content := range []string{`{"alice":true}`, `{}`} {
+		if err := writeFileAtomic(file, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != content {
+			t.Errorf("got %s, want %s", got, content)
+		}
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode is %s, want %s", info.Mode().Perm(), os.FileMode(0600))
+	}
+	files, err := ioutil.ReadDir(filepath.Dir(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("%d files are left, want only %s", len(files), file)
+	}
+}
+
+func TestWriteFileAtomicFailed(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	// a directory can't be replaced by a file
+	file := filepath.Join(dir, "members.json")
+	if err := os.Mkdir(file, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(file, []byte("{}")); err == nil {
+		t.Fatal("directory is replaced")
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("%d files are left, want the temporary file removed", len(files))
+	}
+}
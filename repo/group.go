@@ -0,0 +1,92 @@
+package repo
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/zhao-kun/reminder-tgbot/logging"
+	"github.com/zhao-kun/reminder-tgbot/model"
+)
+
+// Groups is a set of Repo, one for each group
+type Groups interface {
+	// All return Repo of every group in the configured order
+	All() []Repo
+	// Get return Repo of group `name`
+	Get(name string) (Repo, bool)
+	// ByChat return Repo of the group which chat `chatID` belongs to
+	ByChat(chatID int64) (Repo, bool)
+}
+
+type groups struct {
+	repos []Repo
+}
+
+var _ Groups = groups{}
+
+func (g groups) All() []Repo {
+	return g.repos
+}
+
+func (g groups) Get(name string) (Repo, bool) {
+	for _, r := range g.repos {
+		if r.Cfg().Group == name {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+func (g groups) ByChat(chatID int64) (Repo, bool) {
+	for _, r := range g.repos {
+		for _, c := range r.Cfg().Channels {
+			if c == chatID {
+				return r, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// NewGroups return Groups which contains a Repo for each group of `cfg`,
+// names of groups must be unique and a chat can only belong to one group
+func NewGroups(cfg model.Config, l logging.Logger) (Groups, error) {
+	var g groups
+	dataDir, err := absDataDir(cfg)
+	if err != nil {
+		return nil, err
+	}
+	// personal settings are shared by all groups
+	s, err := loadSettings(filepath.Join(dataDir, settingsFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	chats := map[int64]string{}
+	for _, group := range cfg.AllGroups() {
+		if group.Name == "" {
+			return nil, fmt.Errorf("name of group is required")
+		}
+		if names[group.Name] {
+			return nil, fmt.Errorf("group %s is duplicated", group.Name)
+		}
+		names[group.Name] = true
+		// channels may be inherited from the top level
+		gc := cfg.ForGroup(group)
+		for _, c := range gc.Channels {
+			if other, ok := chats[c]; ok {
+				return nil, fmt.Errorf("chat %d belongs to both group %s and %s",
+					c, other, group.Name)
+			}
+			chats[c] = group.Name
+		}
+
+		r, err := open(gc, l.With(logging.F("group", group.Name)), s)
+		if err != nil {
+			return nil, fmt.Errorf("open repo of group %s error %s", group.Name, err)
+		}
+		g.repos = append(g.repos, r)
+	}
+	return g, nil
+}
@@ -0,0 +1,196 @@
+package repo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zhao-kun/reminder-tgbot/logging"
+	"github.com/zhao-kun/reminder-tgbot/model"
+	"github.com/zhao-kun/reminder-tgbot/util"
+)
+
+// checkInFileName is the name of json file of a check in record
+const checkInFileName = "checkin.json"
+
+var (
+	// ErrCheckInNotFound represent the check in record doesn't exist
+	ErrCheckInNotFound = fmt.Errorf("Check in record not found")
+	// ErrInvalidCheckInID represent a malformed check in id
+	ErrInvalidCheckInID = fmt.Errorf("Check in id should be yyyymmdd-user")
+	// ErrInvalidUser represent a user name which can't be stored
+	ErrInvalidUser = fmt.Errorf("User name is invalid")
+	// ErrInvalidStatus represent a unknown status of check in
+	ErrInvalidStatus = fmt.Errorf("Status of check in should be %s or %s",
+		model.CheckInOnTime, model.CheckInLate)
+	// ErrInvalidKind represent a unknown kind of check in
+	ErrInvalidKind = fmt.Errorf("Kind of check in should be %s or %s",
+		model.CheckInOffice, model.CheckInRemote)
+)
+
+// CheckInID return the id of check in record of `user` at `t`
+func CheckInID(user string, t time.Time) string {
+	return fmt.Sprintf("%s-%s", util.GetDate(t), user)
+}
+
+// validateCheckIn return a error if fields of `checkIn` can't be stored
+func validateCheckIn(checkIn model.CheckIn) error {
+	if !isValidUser(checkIn.User) {
+		return ErrInvalidUser
+	}
+	switch checkIn.Kind {
+	case "", model.CheckInOffice, model.CheckInRemote:
+	default:
+		return ErrInvalidKind
+	}
+	switch checkIn.Status {
+	case "", model.CheckInOnTime, model.CheckInLate:
+	default:
+		return ErrInvalidStatus
+	}
+	return nil
+}
+
+func (r repo) Record(checkIn model.CheckIn) error {
+	if err := validateCheckIn(checkIn); err != nil {
+		return err
+	}
+	checkIn.Time = util.GetChinaTimeFromUnix(checkIn.Time.Unix())
+	checkIn.ID = CheckInID(checkIn.User, checkIn.Time)
+	return r.checkIn(checkIn)
+}
+
+func (r repo) Get(id string) (model.CheckIn, error) {
+	checkTime, user, err := parseCheckInID(id)
+	if err != nil {
+		return model.CheckIn{}, err
+	}
+	_, file := r.checkInFilePath(checkTime, user)
+	if !util.IsFileExist(file) {
+		return model.CheckIn{}, ErrCheckInNotFound
+	}
+	checkIn, err := readCheckIn(file)
+	checkIn.Group = r.cfg.Group
+	return checkIn, err
+}
+
+func (r repo) Delete(id string) error {
+	checkTime, user, err := parseCheckInID(id)
+	if err != nil {
+		return err
+	}
+	_, file := r.checkInFilePath(checkTime, user)
+	if !util.IsFileExist(file) {
+		return ErrCheckInNotFound
+	}
+	return os.Remove(file)
+}
+
+func (r repo) CheckIns(query model.CheckInQuery) ([]model.CheckIn, error) {
+	users := []string{query.User}
+	if query.User != "" && !isValidUser(query.User) {
+		return nil, ErrInvalidUser
+	}
+	if query.User == "" {
+		var err error
+		users, err = listDir(r.dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	from, to := "", ""
+	if !query.From.IsZero() {
+		from = util.GetDate(query.From)
+	}
+	if !query.To.IsZero() {
+		to = util.GetDate(query.To)
+	}
+
+	checkIns := []model.CheckIn{}
+	for _, user := range users {
+		// names of other directories may contain metacharacters of glob
+		if !isValidUser(user) {
+			continue
+		}
+		files, err := filepath.Glob(filepath.Join(r.dir, user, "*", "*", "*",
+			checkInFileName))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			checkIn, err := readCheckIn(file)
+			if err != nil {
+				r.l.Warn("skip malformed check in record", logging.F("file", file),
+					logging.Err(err))
+				continue
+			}
+			checkIn.Group = r.cfg.Group
+			date := util.GetDate(checkIn.Time)
+			if (from != "" && date < from) || (to != "" && date > to) {
+				continue
+			}
+			checkIns = append(checkIns, checkIn)
+		}
+	}
+
+	sort.Slice(checkIns, func(i, j int) bool {
+		return checkIns[i].Time.Before(checkIns[j].Time)
+	})
+	return checkIns, nil
+}
+
+// readCheckIn read a check in record from json `file`
+func readCheckIn(file string) (model.CheckIn, error) {
+	var checkIn model.CheckIn
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return checkIn, err
+	}
+	if err := json.Unmarshal(content, &checkIn); err != nil {
+		return checkIn, err
+	}
+	checkIn.Time = util.GetChinaTimeFromUnix(checkIn.Time.Unix())
+	return checkIn, nil
+}
+
+func parseCheckInID(id string) (time.Time, string, error) {
+	parts := strings.SplitN(id, "-", 2)
+	if len(parts) != 2 || !isValidUser(parts[1]) {
+		return time.Time{}, "", ErrInvalidCheckInID
+	}
+	date, err := time.Parse("20060102", parts[0])
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCheckInID
+	}
+	return date, parts[1], nil
+}
+
+// isValidUser return false if `user` can't be used as a directory name or
+// contains metacharacters of filepath.Glob
+func isValidUser(user string) bool {
+	return user != "" && user != "." && user != ".." &&
+		!strings.ContainsAny(user, `/\*?[]`)
+}
+
+func listDir(dir string) ([]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, info := range infos {
+		if info.IsDir() {
+			names = append(names, info.Name())
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,114 @@
+package repo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/zhao-kun/reminder-tgbot/util"
+)
+
+// membersFileName is the name of json file which record users who joined or
+// quit by bot command
+const membersFileName = "members.json"
+
+// ErrAlreadyMember represent the user is tracked already
+var ErrAlreadyMember = fmt.Errorf("User is tracked already")
+
+// ErrNotMember represent the user isn't tracked
+var ErrNotMember = fmt.Errorf("User isn't tracked")
+
+// members record users who joined (true) or quit (false) by bot command,
+// they override `check_users` of configuration
+type members struct {
+	sync.Mutex
+	file  string
+	users map[string]bool
+}
+
+func loadMembers(file string) (*members, error) {
+	m := &members{file: file, users: map[string]bool{}}
+	content, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &m.users); err != nil {
+		return nil, fmt.Errorf("unmarshal %s error %s", file, err)
+	}
+	return m, nil
+}
+
+// apply return `configured` users with overrides applied, users joined by
+// bot command are sorted and appended
+func (m *members) apply(configured []string) []string {
+	m.Lock()
+	defer m.Unlock()
+	if len(m.users) == 0 {
+		return configured
+	}
+
+	users := []string{}
+	for _, u := range configured {
+		if tracked, ok := m.users[u]; !ok || tracked {
+			users = append(users, u)
+		}
+	}
+	var joined []string
+	for u, tracked := range m.users {
+		if tracked && !util.StrInSlice(u, configured) {
+			joined = append(joined, u)
+		}
+	}
+	sort.Strings(joined)
+	return append(users, joined...)
+}
+
+// set record whether `user` is tracked, the override is dropped if it
+// equals to the configuration
+func (m *members) set(configured []string, user string, tracked bool) error {
+	m.Lock()
+	defer m.Unlock()
+
+	users := make(map[string]bool, len(m.users)+1)
+	for u, t := range m.users {
+		users[u] = t
+	}
+	if util.StrInSlice(user, configured) == tracked {
+		delete(users, user)
+	} else {
+		users[user] = tracked
+	}
+
+	content, err := json.Marshal(users)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(m.file, content); err != nil {
+		return err
+	}
+	m.users = users
+	return nil
+}
+
+func (r repo) Join(user string) error {
+	if !isValidUser(user) {
+		return ErrInvalidUser
+	}
+	if util.StrInSlice(user, r.Cfg().CheckUesrs) {
+		return ErrAlreadyMember
+	}
+	return r.members.set(r.cfg.CheckUesrs, user, true)
+}
+
+func (r repo) Quit(user string) error {
+	if !util.StrInSlice(user, r.Cfg().CheckUesrs) {
+		return ErrNotMember
+	}
+	return r.members.set(r.cfg.CheckUesrs, user, false)
+}
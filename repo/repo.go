@@ -1,12 +1,14 @@
 package repo
 
 import (
+	"encoding/json"
 	"fmt"
-	"log"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/zhao-kun/reminder-tgbot/logging"
 	"github.com/zhao-kun/reminder-tgbot/model"
 	"github.com/zhao-kun/reminder-tgbot/util"
 )
@@ -19,65 +21,175 @@ var (
 // Repo is a interface which operation check history
 type Repo interface {
 	model.Cfg
-	// CheckIn record a information of checking in according to message
-	CheckIn(model.Message) error
 	// IsUserNeedCheckIn jude whether the `user` need to check in today
 	IsUserNeedCheckIn(user string) bool
+	// Writable return a error if check in can't be recorded
+	Writable() error
+	// CheckIns return check in records matching `query` ordered by time
+	CheckIns(query model.CheckInQuery) ([]model.CheckIn, error)
+	// Record add a check in record, ID and Group of `checkIn` are ignored
+	Record(checkIn model.CheckIn) error
+	// Delete remove the check in record identified by `id`
+	Delete(id string) error
+	// Get return the check in record identified by `id`
+	Get(id string) (model.CheckIn, error)
+	// CheckInDir return the directory where check in history is stored
+	CheckInDir() string
+	// Amend replace the check in which has the same ID with `checkIn`,
+	// ErrCheckInNotFound is returned if it doesn't exist
+	Amend(checkIn model.CheckIn) error
+	// Audit append `entry` to the audit log
+	Audit(entry model.AuditEntry) error
+	// AuditLog return the latest `limit` entries of audit log about `user`,
+	// newest first, empty `user` matches all entries
+	AuditLog(user string, limit int) ([]model.AuditEntry, error)
+	// Join start tracking `user`, ErrAlreadyMember is returned if `user` is
+	// tracked already
+	Join(user string) error
+	// Quit stop tracking `user`, ErrNotMember is returned if `user` isn't
+	// tracked
+	Quit(user string) error
+	// Settings return personal settings of `user`, they're shared by all
+	// groups
+	Settings(user string) model.UserSettings
+	// SaveSettings replace personal settings of `user`
+	SaveSettings(user string, settings model.UserSettings) error
 }
 
 type repo struct {
 	cfg model.Config
+	l   logging.Logger
+	// dir is where check in history is stored
+	dir     string
+	members *members
+	// auditFile is where audit log is appended to
+	auditFile string
+	settings  *settings
 }
 
 var _ Repo = repo{}
 
+// Cfg return the configuration, CheckUesrs contains users who joined by
+// bot command and excludes users who quit
 func (r repo) Cfg() model.Config {
-	return r.cfg
-}
-
-// CheckIn executed check in by some one
-func (r repo) CheckIn(message model.Message) error {
-	checkTime := util.GetChinaTimeFromUnix(int64(message.Date))
-	return checkIn(checkTime, message.From.Username)
+	cfg := r.cfg
+	cfg.CheckUesrs = r.members.apply(r.cfg.CheckUesrs)
+	return cfg
 }
 
 func (r repo) IsUserNeedCheckIn(user string) bool {
 	now := util.GetChinaTimeNow()
-	file, _ := checkInFilePath(now, user)
+	file, _ := r.checkInFilePath(now, user)
 	if util.IsFileExist(file) {
 		return false
 	}
 	return true
 }
 
-func checkIn(checkTime time.Time, user string) error {
-	path, file := checkInFilePath(checkTime, user)
-	log.Printf("file is %s", file)
+func (r repo) Writable() error {
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return fmt.Errorf("create %s error %s", r.dir, err)
+	}
+	h, err := ioutil.TempFile(r.dir, ".writable")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %s", r.dir, err)
+	}
+	h.Close()
+	return os.Remove(h.Name())
+}
+
+// checkIn write `record` as json to its check in file
+func (r repo) checkIn(record model.CheckIn) error {
+	record.Group = r.cfg.Group
+	path, file := r.checkInFilePath(record.Time, record.User)
+	r.l.Debug("recording check in", logging.User(record.User), logging.F("file", file))
 	if util.IsFileExist(file) {
 		return ErrAlreadyCheckedIn
 	}
 
-	os.MkdirAll(path, 0755)
+	content, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return fmt.Errorf("create %s error %s", path, err)
+	}
 	h, err := os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0600)
 	if err != nil {
 		return err
 	}
 	defer h.Close()
-	_, err = h.WriteString(fmt.Sprintf("%s checkin at %+v", user, checkTime))
+	_, err = h.Write(content)
 	return err
 
 }
 
-// New return a Repo interface
-func New(cfg model.Config) Repo {
-	return repo{cfg}
+// CheckInDir return the directory where check in history is stored
+func (r repo) CheckInDir() string {
+	return r.dir
+}
+
+// New return a Repo interface of group `cfg.Group` which store data in
+// `cfg.DataDir` and log by `l`, error is returned if the directory isn't
+// writable
+func New(cfg model.Config, l logging.Logger) (Repo, error) {
+	dataDir, err := absDataDir(cfg)
+	if err != nil {
+		return nil, err
+	}
+	s, err := loadSettings(filepath.Join(dataDir, settingsFileName))
+	if err != nil {
+		return nil, err
+	}
+	return open(cfg, l, s)
+}
+
+// absDataDir return absolute path of data directory of `cfg`
+func absDataDir(cfg model.Config) (string, error) {
+	if cfg.DataDir == "" {
+		return "", fmt.Errorf("data directory is required")
+	}
+	dataDir, err := filepath.Abs(cfg.DataDir)
+	if err != nil {
+		return "", fmt.Errorf("resolve data directory %s error %s", cfg.DataDir, err)
+	}
+	return dataDir, nil
+}
+
+// open return Repo of group `cfg.Group` which share personal settings `s`
+// with other groups
+func open(cfg model.Config, l logging.Logger, s *settings) (Repo, error) {
+	dataDir, err := absDataDir(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isValidUser(cfg.Group) && cfg.Group != "" {
+		return nil, fmt.Errorf("group name %s is invalid", cfg.Group)
+	}
+
+	// check ins of the default group are stored where early versions did
+	groupDir := dataDir
+	if cfg.Group != "" && cfg.Group != model.DefaultGroup {
+		groupDir = filepath.Join(dataDir, "groups", cfg.Group)
+	}
+
+	r := repo{cfg: cfg, l: l, dir: filepath.Join(groupDir, "checkin_history"),
+		auditFile: filepath.Join(groupDir, auditFileName), settings: s}
+	if err := r.Writable(); err != nil {
+		return nil, err
+	}
+	if r.members, err = loadMembers(filepath.Join(groupDir, membersFileName)); err != nil {
+		return nil, err
+	}
+	l.Info("repo was opened", logging.F("dir", r.dir))
+	return r, nil
 }
 
-func checkInFilePath(checkTime time.Time, user string) (checkinFilePath string, checkinFile string) {
+func (r repo) checkInFilePath(checkTime time.Time, user string) (checkinFilePath string, checkinFile string) {
 	year, mon, day := checkTime.Date()
-	bdir, _ := filepath.Abs(filepath.Dir(os.Args[0]))
-	checkRepoDir := fmt.Sprintf("%s/checkin_history", bdir)
-	checkinFilePath = fmt.Sprintf("%s/%s/%04d/%02d/%02d", checkRepoDir, user, year, mon, day)
-	checkinFile = fmt.Sprintf("%s/checkin", checkinFilePath)
+	checkinFilePath = fmt.Sprintf("%s/%s/%04d/%02d/%02d", r.dir, user, year, mon, day)
+	checkinFile = fmt.Sprintf("%s/%s", checkinFilePath, checkInFileName)
 	return
 }
@@ -0,0 +1,75 @@
+package repo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/zhao-kun/reminder-tgbot/model"
+)
+
+// settingsFileName is the name of json file which record personal settings
+// of users in the data directory, it's shared by all groups
+const settingsFileName = "settings.json"
+
+// settings record personal settings of users changed in private chat
+type settings struct {
+	sync.Mutex
+	file  string
+	users map[string]model.UserSettings
+}
+
+func loadSettings(file string) (*settings, error) {
+	s := &settings{file: file, users: map[string]model.UserSettings{}}
+	content, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &s.users); err != nil {
+		return nil, fmt.Errorf("unmarshal %s error %s", file, err)
+	}
+	return s, nil
+}
+
+func (s *settings) get(user string) model.UserSettings {
+	s.Lock()
+	defer s.Unlock()
+	return s.users[user]
+}
+
+func (s *settings) set(user string, us model.UserSettings) error {
+	s.Lock()
+	defer s.Unlock()
+
+	users := make(map[string]model.UserSettings, len(s.users)+1)
+	for u, v := range s.users {
+		users[u] = v
+	}
+	users[user] = us
+
+	content, err := json.Marshal(users)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.file, content); err != nil {
+		return err
+	}
+	s.users = users
+	return nil
+}
+
+func (r repo) Settings(user string) model.UserSettings {
+	return r.settings.get(user)
+}
+
+func (r repo) SaveSettings(user string, us model.UserSettings) error {
+	if !isValidUser(user) {
+		return ErrInvalidUser
+	}
+	return r.settings.set(user, us)
+}
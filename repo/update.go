@@ -0,0 +1,131 @@
+package repo
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// updatesFileName is the name of file which record ids of recent updates in
+// the data directory, one id per line
+const updatesFileName = "updates.log"
+
+type (
+	// Updates remember ids of recent updates, so an update delivered again
+	// by Telegram is dropped even if the bot was restarted
+	Updates interface {
+		// Add remember `id`, false is returned if it has been seen. `id` is
+		// remembered even if saving it failed, the error is returned
+		Add(id int) (bool, error)
+	}
+
+	// updates keep the latest `size` ids, the oldest one is forgotten when
+	// it's full. Ids are appended to the file, which is compacted to the
+	// latest `size` ids when it has `2 * size` lines
+	updates struct {
+		sync.Mutex
+		file string
+		size int
+		// ids is in the order they were added
+		ids  []int
+		seen map[int]bool
+		// w is the opened file, lines is number of lines of it
+		w     *os.File
+		lines int
+	}
+)
+
+// NewUpdates return Updates which keep `size` ids in `dataDir`
+func NewUpdates(dataDir string, size int) (Updates, error) {
+	u := &updates{file: filepath.Join(dataDir, updatesFileName), size: size,
+		seen: map[int]bool{}}
+	ids, err := readUpdates(u.file)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	u.lines = len(ids)
+	if len(ids) > size {
+		ids = ids[len(ids)-size:]
+	}
+	u.ids = ids
+	for _, id := range u.ids {
+		u.seen[id] = true
+	}
+	return u, nil
+}
+
+// readUpdates read ids of `file`, malformed lines, e.g. the last one written
+// partially when the bot crashed, are skipped
+func readUpdates(file string) ([]int, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		if id, err := strconv.Atoi(scanner.Text()); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, scanner.Err()
+}
+
+func (u *updates) Add(id int) (bool, error) {
+	u.Lock()
+	defer u.Unlock()
+	if u.seen[id] {
+		return false, nil
+	}
+	if len(u.ids) >= u.size {
+		delete(u.seen, u.ids[0])
+		u.ids = u.ids[1:]
+	}
+	u.ids = append(u.ids, id)
+	u.seen[id] = true
+	return true, u.append(id)
+}
+
+// append write `id` to the end of file, the file is compacted if it's too
+// long
+func (u *updates) append(id int) error {
+	if u.w == nil || u.lines >= 2*u.size {
+		return u.compact()
+	}
+	if _, err := fmt.Fprintln(u.w, id); err != nil {
+		return fmt.Errorf("write %s error %s", u.file, err)
+	}
+	u.lines++
+	return nil
+}
+
+// compact replace the file with the kept ids atomically, and open it for
+// appending
+func (u *updates) compact() error {
+	if u.w != nil {
+		u.w.Close()
+		u.w = nil
+	}
+	var buf bytes.Buffer
+	for _, id := range u.ids {
+		fmt.Fprintln(&buf, id)
+	}
+	if err := os.MkdirAll(filepath.Dir(u.file), 0700); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(u.file, buf.Bytes()); err != nil {
+		return err
+	}
+	w, err := os.OpenFile(u.file, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("open %s error %s", u.file, err)
+	}
+	u.w = w
+	u.lines = len(u.ids)
+	return nil
+}
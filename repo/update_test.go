@@ -0,0 +1,121 @@
+package repo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func addUpdates(t *testing.T, u Updates, ids []int) []bool {
+	var added []bool
+	for _, id := range ids {
+		ok, err := u.Add(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		added = append(added, ok)
+	}
+	return added
+}
+
+func TestUpdatesAdd(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+		ids  []int
+		want []bool
+	}{
+		{name: "new ids", size: 3, ids: []int{1, 2, 3}, want: []bool{true, true, true}},
+		{name: "duplicate id", size: 3, ids: []int{1, 2, 1}, want: []bool{true, true, false}},
+		{
+			name: "oldest id is forgotten",
+			size: 2,
+			ids:  []int{1, 2, 3, 1, 3},
+			want: []bool{true, true, true, true, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := tempDir(t)
+			defer os.RemoveAll(dir)
+			u, err := NewUpdates(dir, tt.size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := addUpdates(t, u, tt.ids); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdatesRestart(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	u, err := NewUpdates(dir, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addUpdates(t, u, []int{1, 2, 3, 4})
+
+	// a malformed line is skipped
+	f, err := os.OpenFile(filepath.Join(dir, updatesFileName), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("x\n")
+	f.Close()
+
+	u, err = NewUpdates(dir, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := addUpdates(t, u, []int{4, 3, 2, 1})
+	if want := []bool{false, false, false, true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestUpdatesCompact(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, updatesFileName)
+	u, err := NewUpdates(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id   int
+		want []int
+	}{
+		// the file is created with the first id
+		{id: 1, want: []int{1}},
+		{id: 2, want: []int{1, 2}},
+		{id: 3, want: []int{1, 2, 3}},
+		{id: 4, want: []int{1, 2, 3, 4}},
+		// it has `2 * size` lines, it's compacted to the latest `size` ids
+		{id: 5, want: []int{4, 5}},
+		{id: 6, want: []int{4, 5, 6}},
+	}
+	for _, tt := range tests {
+		addUpdates(t, u, []int{tt.id})
+		got, err := readUpdates(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("after %d: got %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/zhao-kun/reminder-tgbot/i18n"
+	"github.com/zhao-kun/reminder-tgbot/logging"
+	"github.com/zhao-kun/reminder-tgbot/model"
+	"github.com/zhao-kun/reminder-tgbot/repo"
+	"github.com/zhao-kun/reminder-tgbot/util"
+)
+
+// auditLimit is how many entries `/audit` shows
+const auditLimit = 20
+
+var clockPattern = regexp.MustCompile(`^\d{1,2}:\d{2}$`)
+
+// correction is arguments of `/record`, `/amend` and `/revoke`
+type correction struct {
+	user string
+	date time.Time
+	// clock is hh:mm, empty if it's not given
+	clock  string
+	kind   string
+	status string
+	reason string
+}
+
+// parseCorrection parse `user yyyy-mm-dd [hh:mm] [kind] [status] reason`,
+// the optional arguments are only accepted if `withOptions` is true
+func parseCorrection(args []string, withOptions bool) (c correction, err error) {
+	if len(args) < 2 {
+		return c, argsError(i18n.UserAndDateRequired)
+	}
+	c.user = strings.TrimPrefix(args[0], "@")
+	if c.date, err = util.ParseChinaDate(args[1]); err != nil {
+		return c, argsError(i18n.DateFormat)
+	}
+	args = args[2:]
+
+	for withOptions && len(args) > 0 {
+		arg := strings.ToLower(args[0])
+		if c.clock == "" && clockPattern.MatchString(arg) {
+			c.clock = arg
+		} else if kind, ok := checkInKinds[arg]; ok && c.kind == "" {
+			c.kind = kind
+		} else if (arg == model.CheckInOnTime || arg == model.CheckInLate) && c.status == "" {
+			c.status = arg
+		} else {
+			break
+		}
+		args = args[1:]
+	}
+
+	c.reason = strings.Join(args, " ")
+	if c.reason == "" {
+		return c, argsError(i18n.ReasonRequired)
+	}
+	return c, nil
+}
+
+// parseCorrectionWithOptions is parseArgsFunc of `/record` and `/amend`
+func parseCorrectionWithOptions(args []string) (interface{}, error) {
+	return parseCorrection(args, true)
+}
+
+// checkInTime return time of the correction, it's begin of the check in
+// window if clock isn't given
+func (c correction) checkInTime(cfg model.Config) (time.Time, error) {
+	if c.clock == "" {
+		return clockTime(c.date, cfg.Remind.TimeRange.Begin)
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04",
+		c.date.Format("2006-01-02")+" "+c.clock, c.date.Location())
+	if err != nil {
+		return t, argsError(i18n.TimeFormat)
+	}
+	return t, nil
+}
+
+// audit write a change of check in to audit log of `r`
+func audit(r repo.Repo, actor, action string, before, after *model.CheckIn,
+	reason string) error {
+	entry := model.AuditEntry{
+		Time:   util.GetChinaTimeNow(),
+		Actor:  actor,
+		Action: action,
+		Before: before,
+		After:  after,
+		Reason: reason,
+	}
+	for _, c := range []*model.CheckIn{after, before} {
+		if c != nil {
+			entry.User = c.User
+			entry.CheckInID = c.ID
+		}
+	}
+	return r.Audit(entry)
+}
+
+// correctionReply reply a correction command, failure of writing audit log
+// is mentioned
+func correctionReply(r repo.Repo, msg model.Message, l logging.Logger, text string,
+	auditErr error) reply {
+	if auditErr != nil {
+		l.Error("write audit log failed", logging.Err(auditErr))
+		text += tr(r, msg.From, i18n.AuditFailed)
+	}
+	return textReply(newReplyMessage(msg.Chat.ID, msg.MessageID, text))
+}
+
+func processRecord(r repo.Repo, l logging.Logger, msg model.Message, args interface{}) reply {
+	usage := func(err error) reply {
+		return textReply(newReplyMessage(msg.Chat.ID, msg.MessageID,
+			errorText(r, msg.From, err)))
+	}
+	c := args.(correction)
+	if !isNeedCheckIn(r.Cfg().CheckUesrs, c.user) {
+		return usage(errors.New(tr(r, msg.From, i18n.UserNotTracked, c.user)))
+	}
+	t, err := c.checkInTime(r.Cfg())
+	if err != nil {
+		return usage(err)
+	}
+
+	checkIn := model.CheckIn{
+		ID:     repo.CheckInID(c.user, t),
+		User:   c.user,
+		Time:   t,
+		Group:  r.Cfg().Group,
+		Kind:   c.kind,
+		Status: c.status,
+		Note:   c.reason,
+	}
+	if checkIn.Status == "" {
+		checkIn.Status, _ = classifyCheckIn(r.Cfg(), t)
+	}
+	if err := r.Record(checkIn); err == repo.ErrAlreadyCheckedIn {
+		return usage(errors.New(tr(r, msg.From, i18n.UseAmend, c.user,
+			c.date.Format("2006-01-02"))))
+	} else if err != nil {
+		l.Error("record check in failed", logging.F("id", checkIn.ID), logging.Err(err))
+		return usage(err)
+	}
+	l.Info("check in was recorded by admin", logging.F("id", checkIn.ID))
+
+	err = audit(r, msg.From.Username, model.AuditRecord, nil, &checkIn, c.reason)
+	return correctionReply(r, msg, l, tr(r, msg.From, i18n.Recorded,
+		c.user, t.Format("2006-01-02 15:04")), err)
+}
+
+func processAmend(r repo.Repo, l logging.Logger, msg model.Message, args interface{}) reply {
+	usage := func(err error) reply {
+		return textReply(newReplyMessage(msg.Chat.ID, msg.MessageID,
+			errorText(r, msg.From, err)))
+	}
+	c := args.(correction)
+	if c.clock == "" && c.kind == "" && c.status == "" {
+		return usage(errors.New(tr(r, msg.From, i18n.NothingToAmend)))
+	}
+
+	before, err := r.Get(repo.CheckInID(c.user, c.date))
+	if err != nil {
+		return usage(err)
+	}
+	after := before
+	if c.clock != "" {
+		if after.Time, err = c.checkInTime(r.Cfg()); err != nil {
+			return usage(err)
+		}
+		if c.status == "" {
+			after.Status, _ = classifyCheckIn(r.Cfg(), after.Time)
+		}
+	}
+	if c.kind != "" {
+		after.Kind = c.kind
+	}
+	if c.status != "" {
+		after.Status = c.status
+	}
+	if err := r.Amend(after); err != nil {
+		l.Error("amend check in failed", logging.F("id", after.ID), logging.Err(err))
+		return usage(err)
+	}
+	l.Info("check in was amended by admin", logging.F("id", after.ID))
+
+	err = audit(r, msg.From.Username, model.AuditAmend, &before, &after, c.reason)
+	return correctionReply(r, msg, l, tr(r, msg.From, i18n.Amended,
+		c.user, c.date.Format("2006-01-02")), err)
+}
+
+func processRevoke(r repo.Repo, l logging.Logger, msg model.Message, args interface{}) reply {
+	usage := func(err error) reply {
+		return textReply(newReplyMessage(msg.Chat.ID, msg.MessageID,
+			errorText(r, msg.From, err)))
+	}
+	c := args.(correction)
+
+	before, err := r.Get(repo.CheckInID(c.user, c.date))
+	if err != nil {
+		return usage(err)
+	}
+	if err := r.Delete(before.ID); err != nil {
+		l.Error("revoke check in failed", logging.F("id", before.ID), logging.Err(err))
+		return usage(err)
+	}
+	l.Info("check in was revoked by admin", logging.F("id", before.ID))
+
+	err = audit(r, msg.From.Username, model.AuditRevoke, &before, nil, c.reason)
+	return correctionReply(r, msg, l, tr(r, msg.From, i18n.Revoked,
+		c.user, c.date.Format("2006-01-02")), err)
+}
+
+// processAudit reply the latest entries of audit log, `/audit [user]`
+func processAudit(r repo.Repo, l logging.Logger, msg model.Message, args interface{}) reply {
+	var user string
+	if args := args.([]string); len(args) > 0 {
+		user = strings.TrimPrefix(args[0], "@")
+	}
+	resp := newReplyMessage(msg.Chat.ID, msg.MessageID, "")
+
+	entries, err := r.AuditLog(user, auditLimit)
+	if err != nil {
+		l.Error("read audit log failed", logging.Err(err))
+		resp.Text = tr(r, msg.From, i18n.ReadAuditFailed)
+		return textReply(resp)
+	}
+	if len(entries) == 0 {
+		resp.Text = tr(r, msg.From, i18n.NoAudit)
+		return textReply(resp)
+	}
+
+	lines := []string{tr(r, msg.From, i18n.LatestChanges, len(entries))}
+	for _, e := range entries {
+		lines = append(lines, fmt.Sprintf("%s @%s %s %s: %s",
+			util.GetChinaTimeFromUnix(e.Time.Unix()).Format("2006-01-02 15:04"),
+			e.Actor, e.Action, e.CheckInID, e.Reason))
+	}
+	resp.Text = strings.Join(lines, "\n")
+	return textReply(resp)
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zhao-kun/reminder-tgbot/i18n"
+	"github.com/zhao-kun/reminder-tgbot/model"
+)
+
+func TestAdminCommandsInOtherChats(t *testing.T) {
+	cfg := model.Config{
+		Channels:   []int64{100},
+		CheckUesrs: []string{"alice"},
+		Admins:     []string{"boss"},
+	}
+	cfg.Remind.TimeRange = model.TimeRange{Begin: "09:00:00+08:00", End: "18:00:00+08:00"}
+	groupChat := model.Chat{ID: 100, Type: "group"}
+	otherChat := model.Chat{ID: 200, Type: "group"}
+	privateChat := model.Chat{ID: 300, Type: "private"}
+	rejected := i18n.T(nil, "", i18n.GroupChatOnly)
+
+	tests := []struct {
+		name     string
+		chat     model.Chat
+		text     string
+		rejected bool
+		// changes is number of audit entries written by the command
+		changes int
+		// documents is number of files sent by the command
+		documents int
+	}{
+		{name: "record in the group chat", chat: groupChat, text: "/record alice 2021-03-01 missed", changes: 1},
+		{name: "record in other chat", chat: otherChat, text: "/record alice 2021-03-01 missed", rejected: true},
+		{name: "record in private chat", chat: privateChat, text: "/record alice 2021-03-01 missed", rejected: true},
+		{name: "amend in other chat", chat: otherChat, text: "/amend alice 2021-03-01 wfh fix", rejected: true},
+		{name: "revoke in other chat", chat: otherChat, text: "/revoke alice 2021-03-01 wrong", rejected: true},
+		{name: "audit in the group chat", chat: groupChat, text: "/audit"},
+		{name: "audit in private chat", chat: privateChat, text: "/audit", rejected: true},
+		{name: "export in the group chat", chat: groupChat, text: "/export", documents: 1},
+		{name: "export in other chat", chat: otherChat, text: "/export", rejected: true},
+		{name: "export in private chat", chat: privateChat, text: "/export json", rejected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, cleanup := testRepo(t, cfg)
+			defer cleanup()
+			c := &fakeClient{}
+			f := commands.route("", commandMsg(tt.chat, "boss", tt.text))
+			if f == nil {
+				t.Fatal("command isn't routed")
+			}
+			if err := f(context.Background(), c, r, testLogger(t)); err != nil {
+				t.Fatal(err)
+			}
+			if len(c.documents) != tt.documents {
+				t.Fatalf("%d documents are sent, want %d", len(c.documents), tt.documents)
+			}
+			if tt.documents > 0 {
+				return
+			}
+			if len(c.replies) != 1 {
+				t.Fatalf("%d replies are sent", len(c.replies))
+			}
+			if got := c.replies[0].Text == rejected; got != tt.rejected {
+				t.Errorf("reply is %q, rejected %v, want %v", c.replies[0].Text, got, tt.rejected)
+			}
+			log, err := r.AuditLog("", auditLimit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(log) != tt.changes {
+				t.Errorf("%d changes are made, want %d", len(log), tt.changes)
+			}
+		})
+	}
+}
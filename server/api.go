@@ -0,0 +1,280 @@
+package server
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/zhao-kun/reminder-tgbot/export"
+	"github.com/zhao-kun/reminder-tgbot/logging"
+	"github.com/zhao-kun/reminder-tgbot/model"
+	"github.com/zhao-kun/reminder-tgbot/repo"
+	"github.com/zhao-kun/reminder-tgbot/util"
+)
+
+type (
+	checkInsResp struct {
+		CheckIns []model.CheckIn `json:"checkins"`
+	}
+
+	// checkInReq is body of creating a check in, Time is now if it's zero
+	checkInReq struct {
+		User string    `json:"user"`
+		Time time.Time `json:"time"`
+		Kind string    `json:"kind"`
+		Note string    `json:"note"`
+		// Status is classified by policy if it's empty
+		Status string `json:"status"`
+		// Reason is written to audit log
+		Reason string `json:"reason"`
+	}
+
+	userStatus struct {
+		User      string     `json:"user"`
+		CheckedIn bool       `json:"checked_in"`
+		CheckInAt *time.Time `json:"check_in_at,omitempty"`
+		Status    string     `json:"status,omitempty"`
+	}
+
+	usersResp struct {
+		Date  string       `json:"date"`
+		Users []userStatus `json:"users"`
+	}
+)
+
+// apiActor is actor of audit entries of changes made by API
+const apiActor = "api"
+
+// RequireToken wrap `h` to reject request without a valid
+// `Authorization: Bearer <token>` header, browsers may send the token as
+// password of basic authentication, all requests are rejected if no token
+// was configured
+func RequireToken(tokens []string, h rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, req *rest.Request) {
+		var token string
+		if _, password, ok := req.BasicAuth(); ok {
+			token = password
+		} else if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			token = strings.TrimPrefix(auth, "Bearer ")
+		} else {
+			w.Header().Set("WWW-Authenticate", `Basic realm="reminder-tgbot"`)
+			rest.Error(w, "bearer token is required", http.StatusUnauthorized)
+			return
+		}
+
+		for _, t := range tokens {
+			if t != "" && subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+				h(w, req)
+				return
+			}
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="reminder-tgbot"`)
+		rest.Error(w, "token is invalid", http.StatusUnauthorized)
+	}
+}
+
+// ListCheckInsHandle return a handler which list check ins filtered by
+// `user`, `from` and `to` query parameters, dates are formatted as
+// yyyy-mm-dd
+func ListCheckInsHandle(r repo.Repo, l logging.Logger) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, req *rest.Request) {
+		query, err := parseCheckInQuery(req.URL.Query())
+		if err != nil {
+			rest.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		checkIns, err := r.CheckIns(query)
+		if err == repo.ErrInvalidUser {
+			rest.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			l.Error("list check ins failed", logging.Err(err))
+			rest.Error(w, "list check ins failed", http.StatusInternalServerError)
+			return
+		}
+		w.WriteJson(checkInsResp{checkIns})
+	}
+}
+
+// CreateCheckInHandle return a handler which record a check in for a
+// tracked user, it's used by admin to correct history
+func CreateCheckInHandle(r repo.Repo, l logging.Logger) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, req *rest.Request) {
+		var body checkInReq
+		if err := req.DecodeJsonPayload(&body); err != nil {
+			rest.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !isNeedCheckIn(r.Cfg().CheckUesrs, body.User) {
+			rest.Error(w, "user isn't tracked", http.StatusBadRequest)
+			return
+		}
+		if body.Time.IsZero() {
+			body.Time = time.Now()
+		}
+
+		checkIn := model.CheckIn{
+			ID:     repo.CheckInID(body.User, util.GetChinaTimeFromUnix(body.Time.Unix())),
+			User:   body.User,
+			Time:   util.GetChinaTimeFromUnix(body.Time.Unix()),
+			Kind:   body.Kind,
+			Note:   body.Note,
+			Status: body.Status,
+		}
+		if checkIn.Status == "" {
+			// status is unknown if the time is out of the window
+			checkIn.Status, _ = classifyCheckIn(r.Cfg(), checkIn.Time)
+		}
+		err := r.Record(checkIn)
+		if err == repo.ErrAlreadyCheckedIn {
+			rest.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err == repo.ErrInvalidKind || err == repo.ErrInvalidStatus {
+			rest.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			l.Error("record check in failed", logging.User(body.User), logging.Err(err))
+			rest.Error(w, "record check in failed", http.StatusInternalServerError)
+			return
+		}
+		l.Info("check in was recorded by api", logging.User(body.User),
+			logging.F("id", checkIn.ID))
+		if err := audit(r, apiActor, model.AuditRecord, nil, &checkIn,
+			body.Reason); err != nil {
+			l.Error("write audit log failed", logging.Err(err))
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.WriteJson(checkIn)
+	}
+}
+
+// DeleteCheckInHandle return a handler which delete the check in identified
+// by `id` path parameter
+func DeleteCheckInHandle(r repo.Repo, l logging.Logger) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, req *rest.Request) {
+		id := req.PathParam("id")
+		before, err := r.Get(id)
+		if err == nil {
+			err = r.Delete(id)
+		}
+		switch err {
+		case nil:
+			l.Info("check in was deleted by api", logging.F("id", id))
+			if err := audit(r, apiActor, model.AuditRevoke, &before, nil,
+				req.URL.Query().Get("reason")); err != nil {
+				l.Error("write audit log failed", logging.Err(err))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case repo.ErrInvalidCheckInID:
+			rest.Error(w, err.Error(), http.StatusBadRequest)
+		case repo.ErrCheckInNotFound:
+			rest.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			l.Error("delete check in failed", logging.F("id", id), logging.Err(err))
+			rest.Error(w, "delete check in failed", http.StatusInternalServerError)
+		}
+	}
+}
+
+// ListUsersHandle return a handler which list tracked users with their
+// check in status of today
+func ListUsersHandle(r repo.Repo, l logging.Logger) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, req *rest.Request) {
+		resp, err := todayStatus(r)
+		if err != nil {
+			l.Error("list check ins failed", logging.Err(err))
+			rest.Error(w, "list check ins failed", http.StatusInternalServerError)
+			return
+		}
+		w.WriteJson(resp)
+	}
+}
+
+// todayStatus return check in status of tracked users of today
+func todayStatus(r repo.Repo) (usersResp, error) {
+	today := util.GetChinaTimeNow()
+	checkIns, err := r.CheckIns(model.CheckInQuery{From: today, To: today})
+	if err != nil {
+		return usersResp{}, err
+	}
+
+	checked := map[string]model.CheckIn{}
+	for _, c := range checkIns {
+		checked[c.User] = c
+	}
+
+	resp := usersResp{Date: today.Format("2006-01-02"), Users: []userStatus{}}
+	for _, u := range r.Cfg().CheckUesrs {
+		status := userStatus{User: u}
+		if c, ok := checked[u]; ok {
+			status.CheckedIn = true
+			status.CheckInAt = &c.Time
+			status.Status = c.Status
+		}
+		resp.Users = append(resp.Users, status)
+	}
+	return resp, nil
+}
+
+// ExportHandle return a handler which export check ins filtered by `user`,
+// `from` and `to` query parameters as a file in `format`, which is csv,
+// json or excel
+func ExportHandle(r repo.Repo, l logging.Logger) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, req *rest.Request) {
+		params := req.URL.Query()
+		format, err := export.ParseFormat(params.Get("format"))
+		if err != nil {
+			rest.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query, err := parseCheckInQuery(params)
+		if err != nil {
+			rest.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var buf bytes.Buffer
+		err = export.Export(&buf, r, format, query)
+		if err == repo.ErrInvalidUser {
+			rest.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			l.Error("export check ins failed", logging.Err(err))
+			rest.Error(w, "export check ins failed", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", export.ContentType(format))
+		w.Header().Set("Content-Disposition",
+			fmt.Sprintf(`attachment; filename="%s"`, export.FileName(format, query)))
+		w.WriteHeader(http.StatusOK)
+		w.(http.ResponseWriter).Write(buf.Bytes())
+	}
+}
+
+// parseCheckInQuery parse `user`, `from` and `to` query parameters
+func parseCheckInQuery(params url.Values) (model.CheckInQuery, error) {
+	query := model.CheckInQuery{User: params.Get("user")}
+	var err error
+	if from := params.Get("from"); from != "" {
+		if query.From, err = util.ParseChinaDate(from); err != nil {
+			return query, fmt.Errorf("from should be yyyy-mm-dd")
+		}
+	}
+	if to := params.Get("to"); to != "" {
+		if query.To, err = util.ParseChinaDate(to); err != nil {
+			return query, fmt.Errorf("to should be yyyy-mm-dd")
+		}
+	}
+	return query, nil
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+func TestRequireToken(t *testing.T) {
+	tokens := []string{"", "secret", "another"}
+	tests := []struct {
+		name   string
+		tokens []string
+		auth   func(req *http.Request)
+		status int
+	}{
+		{name: "bearer token", tokens: tokens, status: http.StatusOK,
+			auth: func(req *http.Request) { req.Header.Set("Authorization", "Bearer secret") }},
+		{name: "another bearer token", tokens: tokens, status: http.StatusOK,
+			auth: func(req *http.Request) { req.Header.Set("Authorization", "Bearer another") }},
+		{name: "password of basic authentication", tokens: tokens, status: http.StatusOK,
+			auth: func(req *http.Request) { req.SetBasicAuth("anyone", "secret") }},
+		{name: "missing", tokens: tokens, status: http.StatusUnauthorized,
+			auth: func(req *http.Request) {}},
+		{name: "other scheme", tokens: tokens, status: http.StatusUnauthorized,
+			auth: func(req *http.Request) { req.Header.Set("Authorization", "Token secret") }},
+		{name: "invalid bearer token", tokens: tokens, status: http.StatusUnauthorized,
+			auth: func(req *http.Request) { req.Header.Set("Authorization", "Bearer secrets") }},
+		{name: "invalid password", tokens: tokens, status: http.StatusUnauthorized,
+			auth: func(req *http.Request) { req.SetBasicAuth("secret", "wrong") }},
+		{name: "empty token isn't accepted", tokens: tokens, status: http.StatusUnauthorized,
+			auth: func(req *http.Request) { req.Header.Set("Authorization", "Bearer ") }},
+		{name: "no token configured", status: http.StatusUnauthorized,
+			auth: func(req *http.Request) { req.Header.Set("Authorization", "Bearer secret") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			h := RequireToken(tt.tokens, func(w rest.ResponseWriter, req *rest.Request) {
+				called = true
+				w.WriteJson(map[string]bool{"ok": true})
+			})
+			api := rest.NewApi()
+			router, err := rest.MakeRouter(rest.Get("/api/tasks", h))
+			if err != nil {
+				t.Fatal(err)
+			}
+			api.SetApp(router)
+
+			req := httptest.NewRequest("GET", "/api/tasks", nil)
+			tt.auth(req)
+			w := httptest.NewRecorder()
+			api.MakeHandler().ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("status is %d, want %d", w.Code, tt.status)
+			}
+			if called != (tt.status == http.StatusOK) {
+				t.Errorf("handler is called %v", called)
+			}
+			if tt.status == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("browsers aren't asked to authenticate")
+			}
+		})
+	}
+}
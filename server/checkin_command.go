@@ -2,11 +2,15 @@ package server
 
 import (
 	"fmt"
-	"log"
+	"strings"
 	"time"
 
+	"github.com/zhao-kun/reminder-tgbot/i18n"
+	"github.com/zhao-kun/reminder-tgbot/logging"
+	"github.com/zhao-kun/reminder-tgbot/metrics"
 	"github.com/zhao-kun/reminder-tgbot/model"
 	"github.com/zhao-kun/reminder-tgbot/repo"
+	"github.com/zhao-kun/reminder-tgbot/util"
 )
 
 func newReplyMessage(chatID int64, replyID int, text string) model.ReplyMessage {
@@ -19,40 +23,130 @@ func newReplyMessage(chatID int64, replyID int, text string) model.ReplyMessage
 	}
 }
 
-func validateCheckInUser(cfg model.Config, message model.Message) (valid bool, tips string) {
-	return isNeedCheckIn(cfg.CheckUesrs, message.From.Username),
-		fmt.Sprintf("Hi %s, you are good guy, no need to check every day",
-			message.From.Username)
+// countRejection wrap `f` to count rejections of validator `name`
+func countRejection(name string, f validateFunc) validateFunc {
+	return func(r repo.Repo, l logging.Logger, message model.Message) (bool, string) {
+		valid, tips := f(r, l, message)
+		if !valid {
+			metrics.ValidationRejections.Inc(name)
+		}
+		return valid, tips
+	}
+}
+
+func validateCheckInUser(r repo.Repo, l logging.Logger, message model.Message) (valid bool, tips string) {
+	user := message.From.Username
+	if isNeedCheckIn(r.Cfg().CheckUesrs, user) {
+		return true, ""
+	}
+	data := newTemplateData(r, user, time.Unix(int64(message.Date), 0))
+	return false, render(r, l, i18n.NotTracked, data,
+		tr(r, message.From, i18n.NotTracked, user))
 }
 
-func validateSession(cfg model.Config, message model.Message) (valid bool, tips string) {
-	return isSessionAllowToCheckIn(cfg, message.Chat.ID),
-		"Sorry, current session isn't allowed to check in"
+func validateSession(r repo.Repo, l logging.Logger, message model.Message) (valid bool, tips string) {
+	return isSessionAllowToCheckIn(r.Cfg(), message.Chat.ID),
+		tr(r, message.From, i18n.SessionNotAllowed)
 }
 
-func validateCheckInTime(cfg model.Config, message model.Message) (valid bool, tips string) {
+func validateCheckInTime(r repo.Repo, l logging.Logger, message model.Message) (valid bool, tips string) {
+	cfg := r.Cfg()
 	checkInTime := time.Unix(int64(message.Date), 0)
-	return isRemindTime(
-			checkInTime, cfg.Remind.TimeRange.Begin, cfg.Remind.TimeRange.End),
-		fmt.Sprintf("Sorry, please check in at %s - %s", cfg.Remind.TimeRange.Begin,
-			cfg.Remind.TimeRange.End)
+	// the policy was validated when bot started, so error is ignored
+	status, _ := classifyCheckIn(cfg, checkInTime)
+	end := cfg.Policy.Late
+	if end == "" {
+		end = cfg.Remind.TimeRange.End
+	}
+	if status != "" {
+		return true, ""
+	}
+	data := newTemplateData(r, message.From.Username, checkInTime)
+	return false, render(r, l, i18n.OutOfWindow, data,
+		tr(r, message.From, i18n.OutOfWindow, cfg.Remind.TimeRange.Begin, end))
+}
 
+// checkInKinds map arguments of `/checkin` to kind of check in
+var checkInKinds = map[string]string{
+	"office": model.CheckInOffice,
+	"wfh":    model.CheckInRemote,
+	"remote": model.CheckInRemote,
+	"home":   model.CheckInRemote,
 }
 
-func processNone(repo repo.Repo, msg model.Message) model.ReplyMessage {
-	return model.ReplyMessage{}
+// maxNoteLength is max length of note of check in in characters
+const maxNoteLength = 200
+
+// newCheckIn return check in record of `msg` which is
+// `/checkin [office|wfh] [note]` with arguments `args`, a photo or location
+// sent with the command, or replied by the command if it's sent by the same
+// user, is recorded as proof
+func newCheckIn(msg model.Message, args []string) model.CheckIn {
+	checkIn := model.CheckIn{
+		User: msg.From.Username,
+		Time: util.GetChinaTimeFromUnix(int64(msg.Date)),
+	}
+
+	if len(args) > 0 {
+		if kind, ok := checkInKinds[strings.ToLower(args[0])]; ok {
+			checkIn.Kind = kind
+			args = args[1:]
+		}
+	}
+	note := []rune(strings.Join(args, " "))
+	if len(note) > maxNoteLength {
+		note = note[:maxNoteLength]
+	}
+	checkIn.Note = string(note)
+
+	for _, m := range []*model.Message{&msg, msg.ReplyToMessage} {
+		// proof of others can't be taken as own
+		if m == nil || m.From.ID != msg.From.ID {
+			continue
+		}
+		if checkIn.Location == nil && m.Location != nil {
+			checkIn.Location = m.Location
+		}
+		if checkIn.Photo == "" && len(m.Photo) > 0 {
+			// the last one is the largest size
+			checkIn.Photo = m.Photo[len(m.Photo)-1].FileID
+		}
+	}
+	return checkIn
 }
 
-func processCheckIn(r repo.Repo, msg model.Message) model.ReplyMessage {
-	resp := newReplyMessage(msg.Chat.ID, msg.MessageID, fmt.Sprintf("OK! you are checked in @%s", msg.From.Username))
-	err := r.CheckIn(msg)
+func processCheckIn(r repo.Repo, l logging.Logger, msg model.Message, args interface{}) reply {
+	checkIn := newCheckIn(msg, args.([]string))
+	// the check in time was validated, so error is ignored
+	checkIn.Status, _ = classifyCheckIn(r.Cfg(), checkIn.Time)
+
+	resp := newReplyMessage(msg.Chat.ID, msg.MessageID, "")
+	err := r.Record(checkIn)
 	if err != nil {
-		log.Printf("%s checkin at %d failed:%s", msg.Chat.Username, msg.Date, err)
+		l.Warn("check in failed", logging.F("date", msg.Date), logging.Err(err))
 		if err == repo.ErrAlreadyCheckedIn {
-			resp.Text = fmt.Sprintf("Yes, yes, you've already checked in.")
+			data := newTemplateData(r, checkIn.User, checkIn.Time)
+			resp.Text = render(r, l, i18n.AlreadyCheckedIn, data,
+				tr(r, msg.From, i18n.AlreadyCheckedIn))
 		} else {
-			resp.Text = fmt.Sprintf("Sorry, check in failed, please contact the `reminder-tgbot` author.")
+			resp.Text = tr(r, msg.From, i18n.CheckInFailed)
 		}
+		return textReply(resp)
+	}
+
+	text := tr(r, msg.From, i18n.CheckedIn, msg.From.Username)
+	if checkIn.Status == model.CheckInLate {
+		text = tr(r, msg.From, i18n.CheckedInLate, msg.From.Username)
+	}
+	if checkIn.Kind != "" {
+		text = fmt.Sprintf("%s (%s)", text, checkIn.Kind)
 	}
-	return resp
+	data := newTemplateData(r, checkIn.User, checkIn.Time)
+	data.Kind, data.Status = checkIn.Kind, checkIn.Status
+	resp.Text = render(r, l, i18n.CheckedIn, data, text)
+	metrics.CheckInsRecorded.Inc()
+	l.Info("checked in", logging.F("date", msg.Date), logging.F("status", checkIn.Status),
+		logging.F("kind", checkIn.Kind),
+		logging.F("location", checkIn.Location != nil), logging.F("photo", checkIn.Photo != ""))
+	return textReply(resp)
 }
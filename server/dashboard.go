@@ -0,0 +1,266 @@
+package server
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/zhao-kun/reminder-tgbot/logging"
+	"github.com/zhao-kun/reminder-tgbot/model"
+	"github.com/zhao-kun/reminder-tgbot/repo"
+	"github.com/zhao-kun/reminder-tgbot/util"
+)
+
+const (
+	// heatmapWeeks is how many weeks the heatmap of each user covers
+	heatmapWeeks = 26
+	monthLayout  = "2006-01"
+)
+
+type (
+	dashboardData struct {
+		Name      string
+		Group     string
+		Today     usersResp
+		Month     string
+		PrevMonth string
+		NextMonth string
+		Days      []dayColumn
+		Rows      []attendanceRow
+
+		HeatmapWeeks int
+		Heatmaps     []heatmap
+	}
+
+	dayColumn struct {
+		Day     int
+		Weekend bool
+	}
+
+	attendanceRow struct {
+		User   string
+		Cells  []attendanceCell
+		Total  int
+		Remote int
+		Late   int
+	}
+
+	attendanceCell struct {
+		Weekend bool
+		Time    string
+		Remote  bool
+		Late    bool
+		Note    string
+	}
+
+	heatmap struct {
+		User  string
+		Weeks [][]heatmapCell
+		Total int
+	}
+
+	heatmapCell struct {
+		Date      string
+		CheckedIn bool
+		Weekend   bool
+		Future    bool
+	}
+)
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"cellOffset": func(i int) int { return i * 12 },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{if .Name}}{{.Name}} {{end}}{{.Group}} attendance</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #24292e; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #d1d5da; padding: 4px 6px; text-align: center; font-size: 13px; }
+.weekend { background: #f6f8fa; color: #959da5; }
+.yes { background: #2ea44f; color: #fff; }
+.remote { background: #0366d6; color: #fff; }
+.late { box-shadow: inset 0 -3px #f66a0a; }
+.no { background: #f9d0c4; }
+.heatmap { display: inline-block; margin: 0 2em 2em 0; }
+.heatmap svg rect { fill: #ebedf0; }
+.heatmap svg rect.weekend { fill: #f6f8fa; }
+.heatmap svg rect.yes { fill: #2ea44f; }
+.heatmap svg rect.future { fill: none; }
+</style>
+</head>
+<body>
+<h1>{{if .Name}}{{.Name}} {{end}}{{.Group}} attendance</h1>
+
+<h2>Today {{.Today.Date}}</h2>
+<table>
+<tr><th>User</th><th>Status</th><th>Checked in at</th></tr>
+{{range .Today.Users}}<tr>
+<td>{{.User}}</td>
+{{if .CheckedIn}}<td class="yes{{if eq .Status "late"}} late{{end}}">checked in{{if eq .Status "late"}} late{{end}}</td><td>{{.CheckInAt.Format "15:04:05"}}</td>
+{{else}}<td class="no">pending</td><td></td>{{end}}
+</tr>
+{{end}}</table>
+
+<h2>Month {{.Month}}</h2>
+<p><a href="?group={{.Group}}&month={{.PrevMonth}}">&larr; {{.PrevMonth}}</a> | <a href="?group={{.Group}}&month={{.NextMonth}}">{{.NextMonth}} &rarr;</a></p>
+<table>
+<tr><th>User</th>{{range .Days}}<th{{if .Weekend}} class="weekend"{{end}}>{{.Day}}</th>{{end}}<th>Total</th><th>Late</th><th>Remote</th></tr>
+{{range .Rows}}<tr>
+<td>{{.User}}</td>{{range .Cells}}<td class="{{if .Remote}}remote{{else if .Time}}yes{{else if .Weekend}}weekend{{end}}{{if .Late}} late{{end}}" title="{{.Time}}{{if .Late}} late{{end}}{{if .Note}} {{.Note}}{{end}}">{{if .Remote}}W{{else if .Time}}&#10003;{{end}}</td>{{end}}<td>{{.Total}}</td><td>{{.Late}}</td><td>{{.Remote}}</td>
+</tr>
+{{end}}</table>
+<p><span class="yes">&#10003;</span> checked in, <span class="remote">W</span> worked from home, <span class="yes late">&#10003;</span> late</p>
+
+<h2>Last {{.HeatmapWeeks}} weeks</h2>
+{{range .Heatmaps}}<div class="heatmap">
+<div>{{.User}} ({{.Total}} days)</div>
+<svg width="{{len .Weeks | cellOffset}}" height="{{cellOffset 7}}">
+{{range $w, $week := .Weeks}}{{range $d, $cell := $week}}<rect x="{{cellOffset $w}}" y="{{cellOffset $d}}" width="10" height="10" class="{{if $cell.Future}}future{{else if $cell.CheckedIn}}yes{{else if $cell.Weekend}}weekend{{end}}"><title>{{$cell.Date}}</title></rect>{{end}}
+{{end}}</svg>
+</div>
+{{end}}
+</body>
+</html>
+`))
+
+// DashboardHandle return a handler which render attendance dashboard in
+// html, `month` query parameter is formatted as yyyy-mm
+func DashboardHandle(r repo.Repo, l logging.Logger) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, req *rest.Request) {
+		now := util.GetChinaTimeNow()
+		month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		if m := req.URL.Query().Get("month"); m != "" {
+			var err error
+			if month, err = time.ParseInLocation(monthLayout, m, now.Location()); err != nil {
+				rest.Error(w, "month should be yyyy-mm", http.StatusBadRequest)
+				return
+			}
+		}
+
+		data, err := newDashboardData(r, now, month)
+		if err != nil {
+			l.Error("build dashboard failed", logging.Err(err))
+			rest.Error(w, "build dashboard failed", http.StatusInternalServerError)
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := dashboardTemplate.Execute(&buf, data); err != nil {
+			l.Error("render dashboard failed", logging.Err(err))
+			rest.Error(w, "render dashboard failed", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.(http.ResponseWriter).Write(buf.Bytes())
+	}
+}
+
+func newDashboardData(r repo.Repo, now, month time.Time) (dashboardData, error) {
+	today, err := todayStatus(r)
+	if err != nil {
+		return dashboardData{}, err
+	}
+
+	monthEnd := month.AddDate(0, 1, -1)
+	// heatmap starts from sunday, ends at today
+	heatmapStart := now.AddDate(0, 0, -int(now.Weekday())-7*(heatmapWeeks-1))
+	from := month
+	if heatmapStart.Before(from) {
+		from = heatmapStart
+	}
+	to := monthEnd
+	if now.After(to) {
+		to = now
+	}
+	checkIns, err := r.CheckIns(model.CheckInQuery{From: from, To: to})
+	if err != nil {
+		return dashboardData{}, err
+	}
+
+	checked := map[string]model.CheckIn{}
+	for _, c := range checkIns {
+		checked[c.User+util.GetDate(c.Time)] = c
+	}
+
+	data := dashboardData{
+		Name:      r.Cfg().Name,
+		Group:     r.Cfg().Group,
+		Today:     today,
+		Month:     month.Format(monthLayout),
+		PrevMonth: month.AddDate(0, -1, 0).Format(monthLayout),
+		NextMonth: month.AddDate(0, 1, 0).Format(monthLayout),
+
+		HeatmapWeeks: heatmapWeeks,
+	}
+
+	for d := month; !d.After(monthEnd); d = d.AddDate(0, 0, 1) {
+		data.Days = append(data.Days, dayColumn{Day: d.Day(), Weekend: isWeekend(d)})
+	}
+
+	for _, u := range dashboardUsers(r.Cfg().CheckUesrs, checkIns) {
+		row := attendanceRow{User: u}
+		for d := month; !d.After(monthEnd); d = d.AddDate(0, 0, 1) {
+			cell := attendanceCell{Weekend: isWeekend(d)}
+			if c, ok := checked[u+util.GetDate(d)]; ok {
+				cell.Time = c.Time.Format("15:04:05")
+				cell.Remote = c.Kind == model.CheckInRemote
+				cell.Late = c.Status == model.CheckInLate
+				cell.Note = c.Note
+				row.Total++
+				if cell.Remote {
+					row.Remote++
+				}
+				if cell.Late {
+					row.Late++
+				}
+			}
+			row.Cells = append(row.Cells, cell)
+		}
+		data.Rows = append(data.Rows, row)
+
+		hm := heatmap{User: u}
+		for w := 0; w < heatmapWeeks; w++ {
+			week := make([]heatmapCell, 7)
+			for d := range week {
+				date := heatmapStart.AddDate(0, 0, w*7+d)
+				_, ok := checked[u+util.GetDate(date)]
+				week[d] = heatmapCell{
+					Date:      date.Format("2006-01-02"),
+					CheckedIn: ok,
+					Weekend:   isWeekend(date),
+					Future:    util.GetDate(date) > util.GetDate(now),
+				}
+				if ok {
+					hm.Total++
+				}
+			}
+			hm.Weeks = append(hm.Weeks, week)
+		}
+		data.Heatmaps = append(data.Heatmaps, hm)
+	}
+	return data, nil
+}
+
+// dashboardUsers return `members` followed by other users who checked in by
+// `checkIns` in order of name, e.g. who quit or was removed from check_users
+func dashboardUsers(members []string, checkIns []model.CheckIn) []string {
+	users := append([]string{}, members...)
+	var others []string
+	for _, c := range checkIns {
+		if !util.StrInSlice(c.User, users) && !util.StrInSlice(c.User, others) {
+			others = append(others, c.User)
+		}
+	}
+	sort.Strings(others)
+	return append(users, others...)
+}
+
+func isWeekend(t time.Time) bool {
+	return t.Weekday() == time.Saturday || t.Weekday() == time.Sunday
+}
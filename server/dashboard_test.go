@@ -0,0 +1,54 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zhao-kun/reminder-tgbot/model"
+)
+
+func TestNewDashboardData(t *testing.T) {
+	r, cleanup := testRepo(t, model.Config{CheckUesrs: []string{"bob", "alice"}})
+	defer cleanup()
+	// carol quit after checking in, dave checked in only in the heatmap
+	now := chinaDay(2021, 3, 3)
+	month := time.Date(2021, 3, 1, 0, 0, 0, 0, now.Location())
+	checkIns := []model.CheckIn{
+		{User: "alice", Time: chinaDay(2021, 3, 1)},
+		{User: "carol", Time: chinaDay(2021, 3, 1), Kind: model.CheckInRemote},
+		{User: "carol", Time: chinaDay(2021, 3, 2), Status: model.CheckInLate},
+		{User: "dave", Time: chinaDay(2021, 2, 1)},
+	}
+	for _, c := range checkIns {
+		if err := r.Record(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := newDashboardData(r, now, month)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var users []string
+	totals := map[string][3]int{}
+	for _, row := range data.Rows {
+		users = append(users, row.User)
+		totals[row.User] = [3]int{row.Total, row.Remote, row.Late}
+	}
+	if want := []string{"bob", "alice", "carol", "dave"}; !reflect.DeepEqual(users, want) {
+		t.Errorf("rows are %v, want %v", users, want)
+	}
+	want := map[string][3]int{"bob": {}, "alice": {1, 0, 0}, "carol": {2, 1, 1}, "dave": {}}
+	if !reflect.DeepEqual(totals, want) {
+		t.Errorf("totals, remote and late are %v, want %v", totals, want)
+	}
+	heatmaps := map[string]int{}
+	for _, hm := range data.Heatmaps {
+		heatmaps[hm.User] = hm.Total
+	}
+	wantHeatmaps := map[string]int{"bob": 0, "alice": 1, "carol": 2, "dave": 1}
+	if !reflect.DeepEqual(heatmaps, wantHeatmaps) {
+		t.Errorf("heatmaps are %v, want %v", heatmaps, wantHeatmaps)
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/zhao-kun/reminder-tgbot/export"
+	"github.com/zhao-kun/reminder-tgbot/i18n"
+	"github.com/zhao-kun/reminder-tgbot/logging"
+	"github.com/zhao-kun/reminder-tgbot/model"
+	"github.com/zhao-kun/reminder-tgbot/repo"
+	"github.com/zhao-kun/reminder-tgbot/util"
+)
+
+// exportArgs is arguments of `/export`
+type exportArgs struct {
+	format string
+	query  model.CheckInQuery
+}
+
+func validateAdmin(r repo.Repo, l logging.Logger, message model.Message) (valid bool, tips string) {
+	return util.StrInSlice(message.From.Username, r.Cfg().Admins),
+		tr(r, message.From, i18n.AdminOnly, message.From.Username)
+}
+
+// validateGroupChat reject commands sent to chats which aren't channels of
+// the group, they're handled by the first group even if it doesn't own them
+func validateGroupChat(r repo.Repo, l logging.Logger, message model.Message) (valid bool, tips string) {
+	return isSessionAllowToCheckIn(r.Cfg(), message.Chat.ID),
+		tr(r, message.From, i18n.GroupChatOnly)
+}
+
+// parseExportArgs parse `/export [format] [from] [to]` to exportArgs, the
+// date range is the current month by default
+func parseExportArgs(args []string) (interface{}, error) {
+	format, query, err := parseExportRange(args)
+	return exportArgs{format, query}, err
+}
+
+func parseExportRange(args []string) (format string, query model.CheckInQuery, err error) {
+	if len(args) > 3 {
+		return "", query, argsError(i18n.TooManyArgs)
+	}
+
+	now := util.GetChinaTimeNow()
+	query.From = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	query.To = now
+
+	if len(args) > 0 {
+		if format, err = export.ParseFormat(args[0]); err != nil {
+			return "", query, argsError(i18n.UnknownFormat)
+		}
+	} else {
+		format = export.FormatCSV
+	}
+	if len(args) > 1 {
+		if query.From, err = util.ParseChinaDate(args[1]); err != nil {
+			return "", query, argsError(i18n.FromFormat)
+		}
+	}
+	if len(args) > 2 {
+		if query.To, err = util.ParseChinaDate(args[2]); err != nil {
+			return "", query, argsError(i18n.ToFormat)
+		}
+	}
+	return format, query, nil
+}
+
+func processExport(r repo.Repo, l logging.Logger, msg model.Message, args interface{}) reply {
+	format, query := args.(exportArgs).format, args.(exportArgs).query
+
+	var buf bytes.Buffer
+	if err := export.Export(&buf, r, format, query); err != nil {
+		l.Error("export check ins failed", logging.Err(err))
+		return textReply(newReplyMessage(msg.Chat.ID, msg.MessageID,
+			tr(r, msg.From, i18n.ExportFailed)))
+	}
+
+	l.Info("check ins were exported", logging.F("format", format))
+	return documentReply{
+		ChatID:           msg.Chat.ID,
+		ReplyToMessageID: msg.MessageID,
+		Caption: tr(r, msg.From, i18n.ExportCaption,
+			query.From.Format("2006-01-02"), query.To.Format("2006-01-02")),
+		FileName: export.FileName(format, query),
+		Content:  buf.Bytes(),
+	}
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/zhao-kun/reminder-tgbot/logging"
+	"github.com/zhao-kun/reminder-tgbot/model"
+	"github.com/zhao-kun/reminder-tgbot/repo"
+	"github.com/zhao-kun/reminder-tgbot/telegram"
+)
+
+// fakeClient record what the bot sent, errors of Post and Edit are returned
+// by postErr and editErr
+type fakeClient struct {
+	replies    []model.ReplyMessage
+	messages   []model.BotMessage
+	posts      []model.BotMessage
+	stickers   []model.StickerMessage
+	animations []model.AnimationMessage
+	documents  []model.DocumentMessage
+	edits      []model.EditMessage
+	answers    []model.CallbackAnswer
+	// texts is text of messages posted or edited in order
+	texts []string
+
+	postErr error
+	editErr error
+}
+
+var _ telegram.Client = &fakeClient{}
+
+func (c *fakeClient) Reply(ctx context.Context, message model.ReplyMessage) error {
+	c.replies = append(c.replies, message)
+	return nil
+}
+
+func (c *fakeClient) Message(ctx context.Context, message model.BotMessage) error {
+	c.messages = append(c.messages, message)
+	return nil
+}
+
+func (c *fakeClient) Post(ctx context.Context, message model.BotMessage) (int, error) {
+	if c.postErr != nil {
+		return 0, c.postErr
+	}
+	c.posts = append(c.posts, message)
+	c.texts = append(c.texts, message.Text)
+	return len(c.posts), nil
+}
+
+func (c *fakeClient) Sticker(ctx context.Context, message model.StickerMessage) error {
+	c.stickers = append(c.stickers, message)
+	return nil
+}
+
+func (c *fakeClient) Animation(ctx context.Context, message model.AnimationMessage) error {
+	c.animations = append(c.animations, message)
+	return nil
+}
+
+func (c *fakeClient) Document(ctx context.Context, message model.DocumentMessage) error {
+	c.documents = append(c.documents, message)
+	return nil
+}
+
+func (c *fakeClient) Edit(ctx context.Context, message model.EditMessage) error {
+	if c.editErr != nil {
+		return c.editErr
+	}
+	c.edits = append(c.edits, message)
+	c.texts = append(c.texts, message.Text)
+	return nil
+}
+
+func (c *fakeClient) AnswerCallback(ctx context.Context, answer model.CallbackAnswer) error {
+	c.answers = append(c.answers, answer)
+	return nil
+}
+
+func (c *fakeClient) SetCommands(ctx context.Context, commands []model.BotCommand,
+	languageCode string) error {
+	return nil
+}
+
+func (c *fakeClient) GetMe(ctx context.Context) (model.From, error) {
+	return model.From{Username: "CheckInBot"}, nil
+}
+
+func testLogger(t *testing.T) logging.Logger {
+	l, err := logging.New(model.Log{}, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+// testRepo return Repo of `cfg` which store data in a temporary directory,
+// the directory is removed by the returned func
+func testRepo(t *testing.T, cfg model.Config) (repo.Repo, func()) {
+	dir, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.DataDir = dir
+	r, err := repo.New(cfg, testLogger(t))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return r, func() { os.RemoveAll(dir) }
+}
+
+// commandMsg return a message of `text` which is a command sent by `user`
+// to `chat`
+func commandMsg(chat model.Chat, user, text string) model.Message {
+	length := len(text)
+	for i, ch := range text {
+		if ch == ' ' {
+			length = i
+			break
+		}
+	}
+	return model.Message{
+		MessageID: 1,
+		From:      model.From{ID: 1, Username: user},
+		Chat:      chat,
+		Date:      1614567600,
+		Text:      text,
+		Entities:  []model.Entity{{Offset: 0, Length: length, Type: "bot_command"}},
+	}
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/zhao-kun/reminder-tgbot/model"
+	"github.com/zhao-kun/reminder-tgbot/repo"
+)
+
+// groupName return `name` of something belongs to `group`, e.g. a task,
+// name of the default group is omitted to keep compatible with early
+// versions
+func groupName(name, group string) string {
+	if group == "" || group == model.DefaultGroup {
+		return name
+	}
+	return name + ":" + group
+}
+
+// groupRepo return Repo of the group which `chat` belongs to, private chats
+// are handled by the first group which tracks the sender `from`, chats which
+// belong to no group are handled by the first group, so they're rejected by
+// its validators
+func groupRepo(groups repo.Groups, chat model.Chat, from model.From) repo.Repo {
+	if r, ok := groups.ByChat(chat.ID); ok {
+		return r
+	}
+	if chat.Type == privateChat {
+		for _, r := range groups.All() {
+			if isNeedCheckIn(r.Cfg().CheckUesrs, from.Username) {
+				return r
+			}
+		}
+	}
+	return groups.All()[0]
+}
+
+// ByGroup return a handler which serve request by handler made by `h` for
+// the group given by `group` query parameter, it's the first group by
+// default
+func ByGroup(groups repo.Groups, h func(repo.Repo) rest.HandlerFunc) rest.HandlerFunc {
+	handlers := map[string]rest.HandlerFunc{}
+	for _, r := range groups.All() {
+		handlers[r.Cfg().Group] = h(r)
+	}
+	first := handlers[groups.All()[0].Cfg().Group]
+
+	return func(w rest.ResponseWriter, req *rest.Request) {
+		name := req.URL.Query().Get("group")
+		if name == "" {
+			first(w, req)
+			return
+		}
+		handler, ok := handlers[name]
+		if !ok {
+			rest.Error(w, "group "+name+" not found", http.StatusNotFound)
+			return
+		}
+		handler(w, req)
+	}
+}
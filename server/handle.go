@@ -1,11 +1,13 @@
 package server
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"strings"
 	"time"
+	"unicode/utf16"
 
+	"github.com/zhao-kun/reminder-tgbot/logging"
 	"github.com/zhao-kun/reminder-tgbot/model"
 	"github.com/zhao-kun/reminder-tgbot/repo"
 	"github.com/zhao-kun/reminder-tgbot/telegram"
@@ -13,8 +15,17 @@ import (
 )
 
 const (
-	noneOpsCommand string = ""
-	checkInCommand string = "/checkin"
+	checkInCommand  string = "/checkin"
+	exportCommand   string = "/export"
+	joinCommand     string = "/join"
+	quitCommand     string = "/quit"
+	recordCommand   string = "/record"
+	amendCommand    string = "/amend"
+	revokeCommand   string = "/revoke"
+	auditCommand    string = "/audit"
+	helpCommand     string = "/help"
+	startCommand    string = "/start"
+	settingsCommand string = "/settings"
 	//
 	contextTodayIsFestivalKey = "today_is_festival_key"
 )
@@ -25,66 +36,176 @@ type (
 		Code int `json:"code"`
 	}
 
-	//processCommandFunc is func which process dedicated command sent by tg
-	processCommandFunc func(repo.Repo, model.Message) model.ReplyMessage
+	// processCommandFunc is func which process dedicated command sent by
+	// tg, `args` is the result of parseArgsFunc of the command
+	processCommandFunc func(r repo.Repo, l logging.Logger, msg model.Message,
+		args interface{}) reply
+
+	// reply is the response of a command which is sent by telegram.Client
+	reply interface {
+		send(context.Context, telegram.Client) error
+	}
+
+	// textReply reply a command with text
+	textReply model.ReplyMessage
+
+	// documentReply reply a command with a file
+	documentReply model.DocumentMessage
 
 	// commandFunc wrap ProcesschatFunc
-	commandFunc func(telegram.Client, repo.Repo) error
+	commandFunc func(context.Context, telegram.Client, repo.Repo, logging.Logger) error
 
-	validateFunc func(model.Config, model.Message) (bool, string)
-)
+	// validateFunc validate a command before it's processed, tips is
+	// replied if it's invalid
+	validateFunc func(repo.Repo, logging.Logger, model.Message) (bool, string)
 
-var (
-	chatFuncs = map[string]processCommandFunc{
-		checkInCommand: processCheckIn,
-		noneOpsCommand: processNone,
+	// botCommand is a command parsed from a message
+	botCommand struct {
+		// name is the command with leading slash, e.g. `/checkin`
+		name string
+		// bot is the username which the command is addressed to, e.g. `bot`
+		// of `/checkin@bot`, it's empty if the command isn't addressed
+		bot  string
+		args []string
 	}
 )
 
-func isRemindTime(t time.Time, begin, end string) bool {
+// commands is all commands served by the bot
+var commands = newCommands()
+
+func newCommands() *commandRouter {
+	rt := newCommandRouter()
+	rt.register(command{
+		name: checkInCommand,
+		validators: []validateFunc{
+			countRejection("session", validateSession),
+			countRejection("check_in_user", validateCheckInUser),
+			countRejection("check_in_time", validateCheckInTime),
+		},
+		process: processCheckIn,
+	})
+	rt.register(command{
+		name: joinCommand,
+		validators: []validateFunc{
+			countRejection("session", validateSession),
+			countRejection("username", validateUsername),
+		},
+		process: processJoin,
+	})
+	rt.register(command{
+		name: quitCommand,
+		validators: []validateFunc{
+			countRejection("session", validateSession),
+			countRejection("username", validateUsername),
+		},
+		process: processQuit,
+	})
+	rt.register(command{
+		name: startCommand,
+		validators: []validateFunc{
+			countRejection("private", validatePrivate),
+			countRejection("username", validateUsername),
+		},
+		process: processStart,
+	})
+	rt.register(command{
+		name: settingsCommand,
+		validators: []validateFunc{
+			countRejection("private", validatePrivate),
+			countRejection("username", validateUsername),
+		},
+		process: processSettings,
+	})
+	rt.register(command{
+		name:    helpCommand,
+		process: helpFunc(rt),
+	})
+	rt.register(command{
+		name: exportCommand,
+		role: roleAdmin,
+		validators: []validateFunc{
+			countRejection("group_chat", validateGroupChat),
+		},
+		parseArgs: parseExportArgs,
+		process:   processExport,
+	})
+	rt.register(command{
+		name: recordCommand,
+		role: roleAdmin,
+		validators: []validateFunc{
+			countRejection("group_chat", validateGroupChat),
+		},
+		parseArgs: parseCorrectionWithOptions,
+		process:   processRecord,
+	})
+	rt.register(command{
+		name: amendCommand,
+		role: roleAdmin,
+		validators: []validateFunc{
+			countRejection("group_chat", validateGroupChat),
+		},
+		parseArgs: parseCorrectionWithOptions,
+		process:   processAmend,
+	})
+	rt.register(command{
+		name: revokeCommand,
+		role: roleAdmin,
+		validators: []validateFunc{
+			countRejection("group_chat", validateGroupChat),
+		},
+		parseArgs: func(args []string) (interface{}, error) {
+			return parseCorrection(args, false)
+		},
+		process: processRevoke,
+	})
+	rt.register(command{
+		name: auditCommand,
+		role: roleAdmin,
+		validators: []validateFunc{
+			countRejection("group_chat", validateGroupChat),
+		},
+		process: processAudit,
+	})
+	return rt
+}
+
+func (r textReply) send(ctx context.Context, c telegram.Client) error {
+	return c.Reply(ctx, model.ReplyMessage(r))
+}
+
+func (r documentReply) send(ctx context.Context, c telegram.Client) error {
+	return c.Document(ctx, model.DocumentMessage(r))
+}
+
+func isRemindTime(t time.Time, begin, end string) (bool, error) {
 	return timeInRange(t, begin, end)
 }
 
-func timeInRange(t time.Time, begin, end string) bool {
+func timeInRange(t time.Time, begin, end string) (bool, error) {
 	y, m, d := t.Date()
 	beginTimeStr := fmt.Sprintf("%04d-%02d-%02dT%s", y, m, d, begin)
 	endTimeStr := fmt.Sprintf("%04d-%02d-%02dT%s", y, m, d, end)
 
 	beginTime, err := time.Parse(time.RFC3339, beginTimeStr)
 	if err != nil {
-		log.Printf("convert %s to time error %s", beginTimeStr, err)
-		return false
+		return false, fmt.Errorf("convert %s to time error %s", beginTimeStr, err)
 	}
 
 	endTime, err := time.Parse(time.RFC3339, endTimeStr)
 	if err != nil {
-		log.Printf("convert %s to time error %s", endTime, err)
-		return false
+		return false, fmt.Errorf("convert %s to time error %s", endTimeStr, err)
 	}
 
 	if t.After(beginTime) && t.Before(endTime) {
-		return true
+		return true, nil
 	}
-	return false
+	return false, nil
 }
 
 func isNeedCheckIn(checkUsrs []string, usrname string) bool {
 	return util.StrInSlice(usrname, checkUsrs)
 }
 
-func isCommand(ents []model.Entity) (yes bool) {
-	for _, ent := range ents {
-		if ent.Type == "bot_command" {
-			return true
-		}
-	}
-	return
-}
-
-func getChatFuncs(funcName string, funcs map[string]processCommandFunc) processCommandFunc {
-	return funcs[funcName]
-}
-
 func isSessionAllowToCheckIn(cfg model.Config, currentSession int64) bool {
 	for _, c := range cfg.Channels {
 		if c == currentSession {
@@ -96,73 +217,80 @@ func isSessionAllowToCheckIn(cfg model.Config, currentSession int64) bool {
 
 func isChinaTimeZoneNewDay() bool {
 	chinaNow := util.GetChinaTimeNow()
-	if timeInRange(chinaNow, "00:00:00+08:00", "00:11:00+08:00") {
-		return true
-	}
-	return false
+	newDay, _ := timeInRange(chinaNow, "00:00:00+08:00", "00:11:00+08:00")
+	return newDay
 }
 
-func dispatch(cfg model.Config, messages []model.TgMessage,
-	chatFuncs map[string]processCommandFunc,
-	validFuncs ...validateFunc) (commandFunc, error) {
-	var pcf processCommandFunc = nil
-	var currentMsg model.Message
-
-	for _, message := range messages {
-		if message.Message.MessageID > 0 &&
-			isCommand(message.Message.Entities) &&
-			message.Message.From.IsBot == false {
-			commands := strings.Split(message.Message.Text, "@")
-			switch commands[0] {
-			case checkInCommand:
-				pcf = getChatFuncs(checkInCommand, chatFuncs)
-				currentMsg = message.Message
-				break
-			}
+// parseCommand return command of `msg` by its `bot_command` entity, only a
+// command at the beginning of the text is a command. Offset and length of
+// entities are in UTF-16 code units
+func parseCommand(msg model.Message) (cmd botCommand, ok bool) {
+	text := utf16.Encode([]rune(msg.Text))
+	for _, ent := range msg.Entities {
+		if ent.Type != "bot_command" || ent.Offset != 0 {
+			continue
+		}
+		if ent.Length < 2 || ent.Length > len(text) {
+			return cmd, false
 		}
+		name := string(utf16.Decode(text[:ent.Length]))
+		if !strings.HasPrefix(name, "/") {
+			return cmd, false
+		}
+		parts := strings.SplitN(name, "@", 2)
+		cmd.name = parts[0]
+		if len(parts) == 2 {
+			cmd.bot = parts[1]
+		}
+		cmd.args = strings.Fields(string(utf16.Decode(text[ent.Length:])))
+		return cmd, true
 	}
+	return cmd, false
+}
 
-	if pcf != nil {
-		return func(c telegram.Client, r repo.Repo) error {
-			if pcf == nil {
-				return nil
-			}
-			for _, validFunc := range validFuncs {
-				valid, tips := validFunc(cfg, currentMsg)
-				if !valid {
-					reply := newReplyMessage(currentMsg.Chat.ID,
-						currentMsg.MessageID, tips)
-					return c.Reply(reply)
-				}
-			}
-			reply := pcf(r, currentMsg)
-			return c.Reply(reply)
-		}, nil
+// addressedTo return true if the command is sent to bot `botName`, a command
+// without bot name is sent to every bot in the chat. Commands addressed to
+// any bot are accepted if `botName` is unknown
+func (cmd botCommand) addressedTo(botName string) bool {
+	return cmd.bot == "" || botName == "" || strings.EqualFold(cmd.bot, botName)
+}
+
+// commandMessage return `msg` whose Text and Entities is replaced by the
+// caption if it's a photo, so a photo with caption `/checkin` is a command
+func commandMessage(msg model.Message) model.Message {
+	if msg.Text == "" && msg.Caption != "" {
+		msg.Text = msg.Caption
+		msg.Entities = msg.CaptionEntities
 	}
-	return nil, nil
+	return msg
 }
 
-// TelegramServerHandle served `/checkin` command sent by user from tgchannel
-func TelegramServerHandle(c telegram.Client, r repo.Repo, message model.TgMessage) {
+// TelegramServerHandle return a handle which serve commands addressed to bot
+// `botName` sent by user from tgchannel by Repo of the group which the chat
+// belongs to, replies will be canceled when `ctx` is done. It's wrapped by
+// middlewares by Chain
+func TelegramServerHandle(botName string) UpdateHandleFunc {
+	return func(ctx context.Context, c telegram.Client, groups repo.Groups,
+		l logging.Logger, message model.TgMessage) {
+		if query := message.CallbackQuery; query.ID != "" {
+			r := groupRepo(groups, query.Message.Chat, query.From)
+			l = l.With(logging.F("group", r.Cfg().Group))
+			if err := handleCallback(ctx, c, r, l, query); err != nil {
+				l.Error("process callback query failed", logging.Err(err))
+			}
+			return
+		}
 
-	respFunc, err := dispatch(r.Cfg(),
-		[]model.TgMessage{message},
-		chatFuncs,
-		validateSession,
-		validateCheckInUser,
-		validateCheckInTime)
-	if err != nil {
-		log.Printf("processChat error %s", err)
-		return
-	}
+		r := groupRepo(groups, message.Message.Chat, message.Message.From)
+		l = l.With(logging.F("group", r.Cfg().Group))
 
-	if respFunc == nil {
-		return
-	}
+		respFunc := commands.route(botName, commandMessage(message.Message))
+		if respFunc == nil {
+			return
+		}
 
-	if err := respFunc(c, r); err != nil {
-		log.Printf("respFunc run error %s", err)
-		return
+		if err := respFunc(ctx, c, r, l); err != nil {
+			l.Error("reply message failed", logging.Err(err))
+		}
 	}
-	return
 }
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/zhao-kun/reminder-tgbot/model"
+)
+
+func commandEntity(offset, length int) []model.Entity {
+	return []model.Entity{{Offset: offset, Length: length, Type: "bot_command"}}
+}
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		entities []model.Entity
+		want     botCommand
+		ok       bool
+	}{
+		{
+			name:     "command without args",
+			text:     "/checkin",
+			entities: commandEntity(0, 8),
+			want:     botCommand{name: "/checkin", args: []string{}},
+			ok:       true,
+		},
+		{
+			name:     "command with args",
+			text:     "/checkin wfh  working from home",
+			entities: commandEntity(0, 8),
+			want:     botCommand{name: "/checkin", args: []string{"wfh", "working", "from", "home"}},
+			ok:       true,
+		},
+		{
+			name:     "command addressed to a bot",
+			text:     "/checkin@Other wfh",
+			entities: commandEntity(0, 14),
+			want:     botCommand{name: "/checkin", bot: "Other", args: []string{"wfh"}},
+			ok:       true,
+		},
+		{
+			name:     "emoji inside args",
+			text:     "/checkin 👍 done 🎉",
+			entities: commandEntity(0, 8),
+			want:     botCommand{name: "/checkin", args: []string{"👍", "done", "🎉"}},
+			ok:       true,
+		},
+		{
+			name:     "emoji right after the command",
+			text:     "/checkin👍",
+			entities: commandEntity(0, 8),
+			want:     botCommand{name: "/checkin", args: []string{"👍"}},
+			ok:       true,
+		},
+		{
+			// offset and length are in UTF-16 code units, the emoji takes two
+			name:     "emoji before the command",
+			text:     "👍 /checkin",
+			entities: commandEntity(3, 8),
+			ok:       false,
+		},
+		{
+			name:     "command not at the beginning",
+			text:     "please /checkin",
+			entities: commandEntity(7, 8),
+			ok:       false,
+		},
+		{
+			name: "command entity after other entities",
+			text: "/checkin @someone",
+			entities: []model.Entity{
+				{Offset: 9, Length: 8, Type: "mention"},
+				{Offset: 0, Length: 8, Type: "bot_command"},
+			},
+			want: botCommand{name: "/checkin", args: []string{"@someone"}},
+			ok:   true,
+		},
+		{
+			name:     "length past the end",
+			text:     "/checkin",
+			entities: commandEntity(0, 9),
+			ok:       false,
+		},
+		{
+			name:     "length past the end counted in UTF-16",
+			text:     "/a👍",
+			entities: commandEntity(0, 5),
+			ok:       false,
+		},
+		{
+			name:     "length too short",
+			text:     "/ checkin",
+			entities: commandEntity(0, 1),
+			ok:       false,
+		},
+		{
+			name:     "not started with slash",
+			text:     "checkin",
+			entities: commandEntity(0, 7),
+			ok:       false,
+		},
+		{
+			name: "without command entity",
+			text: "/checkin",
+			ok:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, ok := parseCommand(model.Message{Text: tt.text, Entities: tt.entities})
+			if ok != tt.ok {
+				t.Fatalf("ok is %v, want %v", ok, tt.ok)
+			}
+			if ok && !reflect.DeepEqual(cmd, tt.want) {
+				t.Errorf("got %#v, want %#v", cmd, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddressedTo(t *testing.T) {
+	tests := []struct {
+		name    string
+		bot     string
+		botName string
+		want    bool
+	}{
+		{name: "not addressed", bot: "", botName: "CheckInBot", want: true},
+		{name: "addressed to the bot", bot: "CheckInBot", botName: "CheckInBot", want: true},
+		{name: "case of bot name is ignored", bot: "checkinbot", botName: "CheckInBot", want: true},
+		{name: "addressed to other bot", bot: "Other", botName: "CheckInBot", want: false},
+		{name: "bot name is unknown", bot: "Other", botName: "", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := botCommand{name: "/checkin", bot: tt.bot}
+			if got := cmd.addressedTo(tt.botName); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/zhao-kun/reminder-tgbot/repo"
+	"github.com/zhao-kun/reminder-tgbot/task"
+	"github.com/zhao-kun/reminder-tgbot/util"
+)
+
+const (
+	statusOK   = "ok"
+	statusFail = "fail"
+
+	// calendarMaxAge is the max age of calendar before it's reported as
+	// stale
+	calendarMaxAge = 26 * time.Hour
+)
+
+type (
+	// check is the result of a readiness check, failure of an optional
+	// check is reported but the bot is still ready
+	check struct {
+		Name     string `json:"name"`
+		OK       bool   `json:"ok"`
+		Optional bool   `json:"optional,omitempty"`
+		Message  string `json:"message,omitempty"`
+	}
+
+	healthResp struct {
+		Status string  `json:"status"`
+		Checks []check `json:"checks,omitempty"`
+	}
+
+	tasksResp struct {
+		Tasks []task.Status `json:"tasks"`
+	}
+
+	// calendarStatus record the last successful calendar lookup, and
+	// whether days looked up are work days
+	calendarStatus struct {
+		sync.RWMutex
+		updatedAt time.Time
+		// workDays is whether a day is a work day by date `yyyymmdd`
+		workDays map[string]bool
+	}
+)
+
+var calendars = struct {
+	sync.Mutex
+	groups map[string]*calendarStatus
+}{groups: map[string]*calendarStatus{}}
+
+// calendarOf return calendar status of `group`
+func calendarOf(group string) *calendarStatus {
+	calendars.Lock()
+	defer calendars.Unlock()
+	c, ok := calendars.groups[group]
+	if !ok {
+		c = &calendarStatus{}
+		calendars.groups[group] = c
+	}
+	return c
+}
+
+// update record the day of `t` is a work day or not
+func (c *calendarStatus) update(t time.Time, workDay bool) {
+	c.Lock()
+	defer c.Unlock()
+	c.updatedAt = t
+	if c.workDays == nil {
+		c.workDays = map[string]bool{}
+	}
+	c.workDays[util.GetDate(util.GetChinaTimeFromUnix(t.Unix()))] = workDay
+}
+
+// isWorkDay return whether the day of `t` is a work day by the calendar,
+// Monday to Friday are work days if it wasn't looked up on that day
+func (c *calendarStatus) isWorkDay(t time.Time) bool {
+	t = util.GetChinaTimeFromUnix(t.Unix())
+	c.RLock()
+	workDay, ok := c.workDays[util.GetDate(t)]
+	c.RUnlock()
+	if ok {
+		return workDay
+	}
+	return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday
+}
+
+func (c *calendarStatus) lastUpdatedAt() time.Time {
+	c.RLock()
+	defer c.RUnlock()
+	return c.updatedAt
+}
+
+// updatedToday return whether calendar of today was looked up successfully
+func (c *calendarStatus) updatedToday() bool {
+	updatedAt := c.lastUpdatedAt()
+	if updatedAt.IsZero() {
+		return false
+	}
+	return util.GetDate(util.GetChinaTimeFromUnix(updatedAt.Unix())) ==
+		util.GetDate(util.GetChinaTimeNow())
+}
+
+// HealthzHandle report the bot process is alive
+func HealthzHandle(w rest.ResponseWriter, req *rest.Request) {
+	w.WriteJson(healthResp{Status: statusOK})
+}
+
+// ReadyzHandle return a handler which report whether the bot is ready, it
+// checks repo of each group is writable, the calendar of each group was
+// looked up recently and all tasks are alive, the calendar is only reported
+// if `calendarOptional`
+func ReadyzHandle(groups repo.Groups, registry task.Registry,
+	calendarOptional bool) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, req *rest.Request) {
+		now := time.Now()
+		var checks []check
+		for _, r := range groups.All() {
+			checks = append(checks, checkRepo(r), checkCalendar(r.Cfg().Group, calendarOptional, now))
+		}
+		checks = append(checks, checkTasks(registry, now)...)
+
+		resp := healthResp{Status: statusOK, Checks: checks}
+		for _, c := range checks {
+			if !c.OK && !c.Optional {
+				resp.Status = statusFail
+				w.WriteHeader(http.StatusServiceUnavailable)
+				break
+			}
+		}
+		w.WriteJson(resp)
+	}
+}
+
+// TasksHandle return a handler which list status of all registered tasks
+func TasksHandle(registry task.Registry) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, req *rest.Request) {
+		w.WriteJson(tasksResp{Tasks: registry.Tasks()})
+	}
+}
+
+func checkRepo(r repo.Repo) check {
+	name := groupName("repo", r.Cfg().Group)
+	if err := r.Writable(); err != nil {
+		return check{Name: name, Message: err.Error()}
+	}
+	return check{Name: name, OK: true}
+}
+
+func checkCalendar(group string, optional bool, now time.Time) check {
+	name := groupName("calendar", group)
+	updatedAt := calendarOf(group).lastUpdatedAt()
+	if updatedAt.IsZero() {
+		return check{Name: name, Optional: optional,
+			Message: "calendar was never looked up successfully"}
+	}
+	if now.Sub(updatedAt) > calendarMaxAge {
+		return check{Name: name, Optional: optional,
+			Message: "calendar was last looked up at " + updatedAt.Format(time.RFC3339)}
+	}
+	return check{Name: name, OK: true, Optional: optional}
+}
+
+func checkTasks(registry task.Registry, now time.Time) []check {
+	var checks []check
+	for _, status := range registry.Tasks() {
+		c := check{Name: "task:" + status.Name, OK: true}
+		if t, ok := registry.Task(status.Name); !ok || !t.Alive(now) {
+			c.OK = false
+			c.Message = "task is " + status.Status + ", last run at " +
+				status.LastRunAt.Format(time.RFC3339)
+		}
+		checks = append(checks, c)
+	}
+	return checks
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckCalendar(t *testing.T) {
+	now := time.Date(2021, 3, 3, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		updatedAt time.Time
+		optional  bool
+		ok        bool
+	}{
+		{name: "never looked up"},
+		{name: "stale", updatedAt: now.Add(-calendarMaxAge - time.Minute)},
+		{name: "recent", updatedAt: now.Add(-time.Hour), ok: true},
+		{name: "optional and stale", updatedAt: now.Add(-calendarMaxAge - time.Minute), optional: true},
+		{name: "optional and recent", updatedAt: now.Add(-time.Hour), optional: true, ok: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group := "health " + tt.name
+			if !tt.updatedAt.IsZero() {
+				calendarOf(group).update(tt.updatedAt, true)
+			}
+			c := checkCalendar(group, tt.optional, now)
+			if c.OK != tt.ok || c.Optional != tt.optional {
+				t.Errorf("ok is %v and optional is %v, want %v and %v", c.OK, c.Optional, tt.ok,
+					tt.optional)
+			}
+			if !c.OK && c.Message == "" {
+				t.Error("failure isn't explained")
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package server
+
+import (
+	"github.com/zhao-kun/reminder-tgbot/i18n"
+	"github.com/zhao-kun/reminder-tgbot/model"
+	"github.com/zhao-kun/reminder-tgbot/repo"
+)
+
+// userLanguage return language of `from`, it's the language chosen by
+// `/settings`, or the language of the Telegram client
+func userLanguage(r repo.Repo, from model.From) string {
+	if lang := r.Settings(from.Username).Language; lang != "" {
+		return lang
+	}
+	return i18n.Language(from.LanguageCode)
+}
+
+// tr return message `key` in language of `from` formatted with `args`
+func tr(r repo.Repo, from model.From, key i18n.Key, args ...interface{}) string {
+	return i18n.T(r.Cfg().Messages, userLanguage(r, from), key, args...)
+}
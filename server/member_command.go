@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"strings"
+
+	"github.com/zhao-kun/reminder-tgbot/i18n"
+	"github.com/zhao-kun/reminder-tgbot/logging"
+	"github.com/zhao-kun/reminder-tgbot/model"
+	"github.com/zhao-kun/reminder-tgbot/repo"
+	"github.com/zhao-kun/reminder-tgbot/telegram"
+	"github.com/zhao-kun/reminder-tgbot/util"
+)
+
+const (
+	// joinCallback is prefix of callback data of buttons which approve or
+	// reject `/join`, e.g. `join:approve:some_one`
+	joinCallback = "join"
+	joinApprove  = "approve"
+	joinReject   = "reject"
+)
+
+// processCallbackFunc process a callback query whose data starts with a
+// prefix of callbackFuncs
+type processCallbackFunc func(context.Context, telegram.Client, repo.Repo,
+	logging.Logger, model.CallbackQuery) error
+
+var callbackFuncs = map[string]processCallbackFunc{
+	joinCallback:     processJoinCallback,
+	settingsCallback: processSettingsCallback,
+}
+
+func validateUsername(r repo.Repo, l logging.Logger, message model.Message) (valid bool, tips string) {
+	return message.From.Username != "", tr(r, message.From, i18n.UsernameRequired)
+}
+
+func processJoin(r repo.Repo, l logging.Logger, msg model.Message, args interface{}) reply {
+	user := msg.From.Username
+	resp := newReplyMessage(msg.Chat.ID, msg.MessageID, tr(r, msg.From, i18n.Welcome, user))
+	if isNeedCheckIn(r.Cfg().CheckUesrs, user) {
+		resp.Text = tr(r, msg.From, i18n.AlreadyJoined, user)
+		return textReply(resp)
+	}
+
+	if r.Cfg().JoinApproval && !util.StrInSlice(user, r.Cfg().Admins) {
+		resp.Text = tr(r, msg.From, i18n.JoinPending, user)
+		resp.ReplyMarkup = &model.InlineKeyboardMarkup{
+			InlineKeyboard: [][]model.InlineKeyboardButton{{
+				{Text: tr(r, msg.From, i18n.Approve),
+					CallbackData: joinCallbackData(joinApprove, user)},
+				{Text: tr(r, msg.From, i18n.Reject),
+					CallbackData: joinCallbackData(joinReject, user)},
+			}},
+		}
+		l.Info("join is waiting for approval")
+		return textReply(resp)
+	}
+
+	if err := r.Join(user); err != nil {
+		l.Error("join failed", logging.Err(err))
+		resp.Text = tr(r, msg.From, i18n.JoinFailed)
+		return textReply(resp)
+	}
+	l.Info("user joined")
+	return textReply(resp)
+}
+
+func processQuit(r repo.Repo, l logging.Logger, msg model.Message, args interface{}) reply {
+	user := msg.From.Username
+	resp := newReplyMessage(msg.Chat.ID, msg.MessageID, tr(r, msg.From, i18n.Bye, user))
+	err := r.Quit(user)
+	if err == repo.ErrNotMember {
+		resp.Text = tr(r, msg.From, i18n.NotJoined, user)
+		return textReply(resp)
+	}
+	if err != nil {
+		l.Error("quit failed", logging.Err(err))
+		resp.Text = tr(r, msg.From, i18n.QuitFailed)
+		return textReply(resp)
+	}
+	l.Info("user quit")
+	return textReply(resp)
+}
+
+func joinCallbackData(action, user string) string {
+	return strings.Join([]string{joinCallback, action, user}, ":")
+}
+
+// processJoinCallback approve or reject a `/join` request, only admins are
+// allowed to do it
+func processJoinCallback(ctx context.Context, c telegram.Client, r repo.Repo,
+	l logging.Logger, query model.CallbackQuery) error {
+	answer := model.CallbackAnswer{CallbackQueryID: query.ID}
+	admin := query.From.Username
+	if !util.StrInSlice(admin, r.Cfg().Admins) {
+		answer.Text = tr(r, query.From, i18n.AdminOnly, admin)
+		return c.AnswerCallback(ctx, answer)
+	}
+
+	parts := strings.SplitN(query.Data, ":", 3)
+	if len(parts) != 3 {
+		answer.Text = tr(r, query.From, i18n.InvalidRequest)
+		return c.AnswerCallback(ctx, answer)
+	}
+	action, user := parts[1], parts[2]
+	l = l.With(logging.F("member", user))
+
+	var text string
+	switch action {
+	case joinApprove:
+		if err := r.Join(user); err != nil && err != repo.ErrAlreadyMember {
+			l.Error("join failed", logging.Err(err))
+			answer.Text = tr(r, query.From, i18n.JoinFailed)
+			return c.AnswerCallback(ctx, answer)
+		}
+		l.Info("join was approved")
+		text = tr(r, query.From, i18n.JoinApproved, user, admin)
+	case joinReject:
+		l.Info("join was rejected")
+		text = tr(r, query.From, i18n.JoinRejected, user, admin)
+	default:
+		answer.Text = tr(r, query.From, i18n.InvalidRequest)
+		return c.AnswerCallback(ctx, answer)
+	}
+
+	err := c.Edit(ctx, model.EditMessage{
+		ChatID:    query.Message.Chat.ID,
+		MessageID: query.Message.MessageID,
+		Text:      text,
+	})
+	if err == telegram.ErrNotModified {
+		err = nil
+	}
+	if err != nil {
+		answer.Text = tr(r, query.From, i18n.EditFailed)
+	}
+	// the button keeps loading until the callback is answered
+	if answerErr := c.AnswerCallback(ctx, answer); err == nil {
+		err = answerErr
+	}
+	return err
+}
+
+// handleCallback process `query` by processCallbackFunc matching prefix of
+// its data
+func handleCallback(ctx context.Context, c telegram.Client, r repo.Repo,
+	l logging.Logger, query model.CallbackQuery) error {
+	prefix := strings.SplitN(query.Data, ":", 2)[0]
+	f, ok := callbackFuncs[prefix]
+	if !ok {
+		l.Warn("unknown callback query", logging.F("data", query.Data))
+		return c.AnswerCallback(ctx, model.CallbackAnswer{CallbackQueryID: query.ID})
+	}
+	return f(ctx, c, r, l.With(logging.F("callback", prefix)), query)
+}
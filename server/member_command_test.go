@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/zhao-kun/reminder-tgbot/i18n"
+	"github.com/zhao-kun/reminder-tgbot/model"
+	"github.com/zhao-kun/reminder-tgbot/telegram"
+	"github.com/zhao-kun/reminder-tgbot/util"
+)
+
+func TestProcessJoin(t *testing.T) {
+	chat := model.Chat{ID: 100, Type: "group"}
+	tests := []struct {
+		name     string
+		user     string
+		approval bool
+		want     string
+		// buttons is whether approve and reject buttons are attached
+		buttons bool
+		joined  bool
+	}{
+		{name: "join", user: "bob", want: i18n.T(nil, "", i18n.Welcome, "bob"), joined: true},
+		{name: "already joined", user: "alice", want: i18n.T(nil, "", i18n.AlreadyJoined, "alice"),
+			joined: true},
+		{name: "waiting for approval", user: "bob", approval: true,
+			want: i18n.T(nil, "", i18n.JoinPending, "bob"), buttons: true},
+		{name: "admins don't need approval", user: "boss", approval: true,
+			want: i18n.T(nil, "", i18n.Welcome, "boss"), joined: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := model.Config{
+				Channels:     []int64{chat.ID},
+				CheckUesrs:   []string{"alice"},
+				Admins:       []string{"boss"},
+				JoinApproval: tt.approval,
+			}
+			r, cleanup := testRepo(t, cfg)
+			defer cleanup()
+			c := &fakeClient{}
+			f := commands.route("", commandMsg(chat, tt.user, "/join"))
+			if f == nil {
+				t.Fatal("command isn't routed")
+			}
+			if err := f(context.Background(), c, r, testLogger(t)); err != nil {
+				t.Fatal(err)
+			}
+			if len(c.replies) != 1 || c.replies[0].Text != tt.want {
+				t.Fatalf("replies are %+v, want %q", c.replies, tt.want)
+			}
+			markup := c.replies[0].ReplyMarkup
+			if buttons := markup != nil; buttons != tt.buttons {
+				t.Fatalf("buttons are attached %v, want %v", buttons, tt.buttons)
+			}
+			if tt.buttons {
+				row := markup.InlineKeyboard[0]
+				if len(row) != 2 || row[0].CallbackData != "join:approve:"+tt.user ||
+					row[1].CallbackData != "join:reject:"+tt.user {
+					t.Errorf("buttons are %+v", row)
+				}
+			}
+			if joined := util.StrInSlice(tt.user, r.Cfg().CheckUesrs); joined != tt.joined {
+				t.Errorf("%s is joined %v, want %v", tt.user, joined, tt.joined)
+			}
+		})
+	}
+}
+
+func TestProcessJoinCallback(t *testing.T) {
+	tests := []struct {
+		name  string
+		admin string
+		data  string
+		// edit is text of the approval message edited, it isn't edited if
+		// it's empty
+		edit    string
+		editErr error
+		answer  string
+		joined  bool
+		err     bool
+	}{
+		{name: "approve", admin: "boss", data: "join:approve:bob",
+			edit: i18n.T(nil, "", i18n.JoinApproved, "bob", "boss"), joined: true},
+		{name: "reject", admin: "boss", data: "join:reject:bob",
+			edit: i18n.T(nil, "", i18n.JoinRejected, "bob", "boss")},
+		{name: "message isn't modified", admin: "boss", data: "join:approve:bob",
+			editErr: telegram.ErrNotModified, joined: true},
+		{name: "edit failed", admin: "boss", data: "join:approve:bob",
+			editErr: errors.New("message to edit not found"),
+			answer:  i18n.T(nil, "", i18n.EditFailed), joined: true, err: true},
+		{name: "not an admin", admin: "alice", data: "join:approve:bob",
+			answer: i18n.T(nil, "", i18n.AdminOnly, "alice")},
+		{name: "unknown action", admin: "boss", data: "join:ignore:bob",
+			answer: i18n.T(nil, "", i18n.InvalidRequest)},
+		{name: "malformed data", admin: "boss", data: "join:approve",
+			answer: i18n.T(nil, "", i18n.InvalidRequest)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := model.Config{
+				Channels:     []int64{100},
+				CheckUesrs:   []string{"alice"},
+				Admins:       []string{"boss"},
+				JoinApproval: true,
+			}
+			r, cleanup := testRepo(t, cfg)
+			defer cleanup()
+			c := &fakeClient{editErr: tt.editErr}
+			query := model.CallbackQuery{
+				ID:      "query",
+				From:    model.From{ID: 2, Username: tt.admin},
+				Message: model.Message{MessageID: 7, Chat: model.Chat{ID: 100, Type: "group"}},
+				Data:    tt.data,
+			}
+			err := handleCallback(context.Background(), c, r, testLogger(t), query)
+			if (err != nil) != tt.err {
+				t.Fatalf("error is %v, want error %v", err, tt.err)
+			}
+			if len(c.answers) != 1 || c.answers[0].CallbackQueryID != "query" ||
+				c.answers[0].Text != tt.answer {
+				t.Fatalf("answers are %+v, want %q", c.answers, tt.answer)
+			}
+			if tt.edit == "" {
+				if len(c.edits) != 0 {
+					t.Errorf("message is edited to %q", c.edits[0].Text)
+				}
+			} else if len(c.edits) != 1 || c.edits[0].Text != tt.edit ||
+				c.edits[0].ChatID != 100 || c.edits[0].MessageID != 7 {
+				t.Errorf("edits are %+v, want %q", c.edits, tt.edit)
+			}
+			if joined := util.StrInSlice("bob", r.Cfg().CheckUesrs); joined != tt.joined {
+				t.Errorf("bob is joined %v, want %v", joined, tt.joined)
+			}
+		})
+	}
+}
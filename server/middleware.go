@@ -0,0 +1,205 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/zhao-kun/reminder-tgbot/logging"
+	"github.com/zhao-kun/reminder-tgbot/metrics"
+	"github.com/zhao-kun/reminder-tgbot/model"
+	"github.com/zhao-kun/reminder-tgbot/repo"
+	"github.com/zhao-kun/reminder-tgbot/telegram"
+)
+
+const (
+	// maxRateWindows is how many users are counted before ended windows
+	// are forgotten
+	maxRateWindows = 1000
+
+	defaultRateLimitUpdates  = 20
+	defaultRateLimitInterval = "1m"
+)
+
+type (
+	// UpdateHandleFunc process an update sent by Telegram
+	UpdateHandleFunc func(ctx context.Context, c telegram.Client, groups repo.Groups,
+		l logging.Logger, update model.TgMessage)
+
+	// Middleware wrap an UpdateHandleFunc to do something before or after it
+	Middleware func(UpdateHandleFunc) UpdateHandleFunc
+
+	// rateLimiter count updates of each user in fixed windows
+	rateLimiter struct {
+		sync.Mutex
+		limit    int
+		interval time.Duration
+		windows  map[int]*rateWindow
+	}
+
+	rateWindow struct {
+		begin time.Time
+		count int
+	}
+)
+
+// Chain return `h` wrapped by `middlewares`, the first one is the outermost
+func Chain(h UpdateHandleFunc, middlewares ...Middleware) UpdateHandleFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// updateSender return chat and sender of `update`, which is a message or a
+// callback query
+func updateSender(update model.TgMessage) (int64, model.From) {
+	if query := update.CallbackQuery; query.ID != "" {
+		return query.Message.Chat.ID, query.From
+	}
+	return update.Message.Chat.ID, update.Message.From
+}
+
+// Recover log panics of handling an update instead of crashing the bot
+func Recover() Middleware {
+	return func(next UpdateHandleFunc) UpdateHandleFunc {
+		return func(ctx context.Context, c telegram.Client, groups repo.Groups,
+			l logging.Logger, update model.TgMessage) {
+			defer func() {
+				if err := recover(); err != nil {
+					metrics.UpdatePanics.Inc()
+					l.Error("handle update panicked", logging.UpdateID(update.UpdateID),
+						logging.F("panic", fmt.Sprint(err)),
+						logging.F("stack", string(debug.Stack())))
+				}
+			}()
+			next(ctx, c, groups, l, update)
+		}
+	}
+}
+
+// Measure observe how long it takes to handle updates
+func Measure() Middleware {
+	return func(next UpdateHandleFunc) UpdateHandleFunc {
+		return func(ctx context.Context, c telegram.Client, groups repo.Groups,
+			l logging.Logger, update model.TgMessage) {
+			begin := time.Now()
+			next(ctx, c, groups, l, update)
+			metrics.UpdateDuration.Observe(time.Since(begin).Seconds())
+		}
+	}
+}
+
+// Log add update id, chat and sender of the update to logger
+func Log() Middleware {
+	return func(next UpdateHandleFunc) UpdateHandleFunc {
+		return func(ctx context.Context, c telegram.Client, groups repo.Groups,
+			l logging.Logger, update model.TgMessage) {
+			chatID, from := updateSender(update)
+			l = l.With(logging.UpdateID(update.UpdateID), logging.ChatID(chatID),
+				logging.User(from.Username))
+			l.Info("update is comming")
+			begin := time.Now()
+			next(ctx, c, groups, l, update)
+			l.Debug("update was handled", logging.F("duration", time.Since(begin)))
+		}
+	}
+}
+
+// Dedup drop updates which were seen by `updates`, Telegram delivers an
+// update again if the webhook doesn't respond in time
+func Dedup(updates repo.Updates) Middleware {
+	return func(next UpdateHandleFunc) UpdateHandleFunc {
+		return func(ctx context.Context, c telegram.Client, groups repo.Groups,
+			l logging.Logger, update model.TgMessage) {
+			added, err := updates.Add(update.UpdateID)
+			if err != nil {
+				// the update is still handled, it may be handled again
+				// if the bot is restarted
+				l.Error("save update id failed", logging.Err(err))
+			}
+			if !added {
+				metrics.UpdatesDropped.Inc("duplicate")
+				l.Info("duplicate update was dropped")
+				return
+			}
+			next(ctx, c, groups, l, update)
+		}
+	}
+}
+
+// Authorize drop updates which aren't sent by a user, e.g. messages of
+// other bots
+func Authorize() Middleware {
+	return func(next UpdateHandleFunc) UpdateHandleFunc {
+		return func(ctx context.Context, c telegram.Client, groups repo.Groups,
+			l logging.Logger, update model.TgMessage) {
+			if _, from := updateSender(update); from.ID == 0 || from.IsBot {
+				metrics.UpdatesDropped.Inc("unauthorized")
+				l.Debug("update isn't sent by a user")
+				return
+			}
+			next(ctx, c, groups, l, update)
+		}
+	}
+}
+
+// allow return true if user `id` doesn't exceed the limit at `now`
+func (rl *rateLimiter) allow(id int, now time.Time) bool {
+	rl.Lock()
+	defer rl.Unlock()
+	w, ok := rl.windows[id]
+	if !ok || now.Sub(w.begin) >= rl.interval {
+		if len(rl.windows) >= maxRateWindows {
+			rl.expire(now)
+		}
+		w = &rateWindow{begin: now}
+		rl.windows[id] = w
+	}
+	w.count++
+	return w.count <= rl.limit
+}
+
+// expire forget windows which were ended
+func (rl *rateLimiter) expire(now time.Time) {
+	for id, w := range rl.windows {
+		if now.Sub(w.begin) >= rl.interval {
+			delete(rl.windows, id)
+		}
+	}
+}
+
+// RateLimit drop updates of a user which exceed the limit of `cfg`, it's
+// disabled if the limit is negative
+func RateLimit(cfg model.RateLimit) (Middleware, error) {
+	limit := cfg.Updates
+	if limit == 0 {
+		limit = defaultRateLimitUpdates
+	}
+	interval := cfg.Interval
+	if interval == "" {
+		interval = defaultRateLimitInterval
+	}
+	d, err := time.ParseDuration(interval)
+	if err != nil || d <= 0 {
+		return nil, fmt.Errorf("rate limit interval %s is invalid", interval)
+	}
+
+	rl := &rateLimiter{limit: limit, interval: d, windows: map[int]*rateWindow{}}
+	return func(next UpdateHandleFunc) UpdateHandleFunc {
+		if limit < 0 {
+			return next
+		}
+		return func(ctx context.Context, c telegram.Client, groups repo.Groups,
+			l logging.Logger, update model.TgMessage) {
+			if _, from := updateSender(update); !rl.allow(from.ID, time.Now()) {
+				metrics.UpdatesDropped.Inc("rate_limited")
+				l.Warn("update was dropped by rate limit")
+				return
+			}
+			next(ctx, c, groups, l, update)
+		}
+	}, nil
+}
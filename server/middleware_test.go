@@ -0,0 +1,78 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	type step struct {
+		id    int
+		after time.Duration
+		want  bool
+	}
+	tests := []struct {
+		name  string
+		limit int
+		steps []step
+	}{
+		{
+			name:  "updates within the limit",
+			limit: 2,
+			steps: []step{{1, 0, true}, {1, time.Second, true}},
+		},
+		{
+			name:  "updates beyond the limit",
+			limit: 2,
+			steps: []step{{1, 0, true}, {1, 0, true}, {1, 0, false}, {1, 59 * time.Second, false}},
+		},
+		{
+			name:  "window ends after the interval",
+			limit: 1,
+			steps: []step{{1, 0, true}, {1, 30 * time.Second, false}, {1, time.Minute, true},
+				{1, 90 * time.Second, false}},
+		},
+		{
+			name:  "users are counted separately",
+			limit: 1,
+			steps: []step{{1, 0, true}, {2, 0, true}, {1, 0, false}, {2, 0, false}},
+		},
+		{
+			name:  "zero limit",
+			limit: 0,
+			steps: []step{{1, 0, false}},
+		},
+	}
+	begin := time.Date(2021, 3, 1, 9, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl := &rateLimiter{limit: tt.limit, interval: time.Minute,
+				windows: map[int]*rateWindow{}}
+			for i, s := range tt.steps {
+				if got := rl.allow(s.id, begin.Add(s.after)); got != s.want {
+					t.Errorf("step %d: got %v, want %v", i, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterExpire(t *testing.T) {
+	rl := &rateLimiter{limit: 1, interval: time.Minute, windows: map[int]*rateWindow{}}
+	begin := time.Date(2021, 3, 1, 9, 0, 0, 0, time.UTC)
+	for id := 0; id < maxRateWindows; id++ {
+		rl.allow(id, begin)
+	}
+	rl.allow(maxRateWindows, begin.Add(time.Second))
+	if len(rl.windows) != maxRateWindows+1 {
+		t.Fatalf("windows are expired before they end, %d are left", len(rl.windows))
+	}
+
+	rl.allow(maxRateWindows+1, begin.Add(time.Minute))
+	if len(rl.windows) != 2 {
+		t.Errorf("ended windows should be expired, %d are left", len(rl.windows))
+	}
+	if rl.allow(maxRateWindows, begin.Add(time.Minute)) {
+		t.Error("window which isn't ended should be kept")
+	}
+}
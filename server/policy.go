@@ -0,0 +1,90 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zhao-kun/reminder-tgbot/model"
+	"github.com/zhao-kun/reminder-tgbot/util"
+)
+
+// checkInWindow is the check in policy of a day
+type checkInWindow struct {
+	begin  time.Time
+	onTime time.Time
+	end    time.Time
+}
+
+// clockTime return time of `clock` formatted as `15:04:05+08:00` at the
+// date of `t`
+func clockTime(t time.Time, clock string) (time.Time, error) {
+	y, m, d := t.Date()
+	str := fmt.Sprintf("%04d-%02d-%02dT%s", y, m, d, clock)
+	result, err := time.Parse(time.RFC3339, str)
+	if err != nil {
+		return result, fmt.Errorf("convert %s to time error %s", str, err)
+	}
+	return result, nil
+}
+
+// newCheckInWindow return the check in window of `cfg` at the date of `t`
+// in `Asia/Shanghai`, late window ends at end of remind time range if
+// it's not configured, and there is no late window if on time cutoff isn't
+// configured
+func newCheckInWindow(cfg model.Config, t time.Time) (w checkInWindow, err error) {
+	t = util.GetChinaTimeFromUnix(t.Unix())
+	policy := cfg.Policy
+
+	if w.begin, err = clockTime(t, cfg.Remind.TimeRange.Begin); err != nil {
+		return w, err
+	}
+	late := policy.Late
+	if late == "" {
+		late = cfg.Remind.TimeRange.End
+	}
+	if w.end, err = clockTime(t, late); err != nil {
+		return w, err
+	}
+	onTime := policy.OnTime
+	if onTime == "" {
+		onTime = late
+	}
+	if w.onTime, err = clockTime(t, onTime); err != nil {
+		return w, err
+	}
+	if policy.Grace != "" {
+		grace, err := time.ParseDuration(policy.Grace)
+		if err != nil {
+			return w, fmt.Errorf("parse grace %s error %s", policy.Grace, err)
+		}
+		w.onTime = w.onTime.Add(grace)
+	}
+
+	if w.end.Before(w.begin) || w.onTime.Before(w.begin) {
+		return w, fmt.Errorf("on time cutoff and late window should be after %s",
+			cfg.Remind.TimeRange.Begin)
+	}
+	return w, nil
+}
+
+// classify return status of check in at `t`, empty string means the window
+// is closed
+func (w checkInWindow) classify(t time.Time) string {
+	if t.Before(w.begin) || t.After(w.end) {
+		return ""
+	}
+	if t.After(w.onTime) {
+		return model.CheckInLate
+	}
+	return model.CheckInOnTime
+}
+
+// classifyCheckIn return status of check in at `t` according to policy of
+// `cfg`, empty string means the window is closed
+func classifyCheckIn(cfg model.Config, t time.Time) (string, error) {
+	w, err := newCheckInWindow(cfg, t)
+	if err != nil {
+		return "", err
+	}
+	return w.classify(t), nil
+}
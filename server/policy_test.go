@@ -0,0 +1,148 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zhao-kun/reminder-tgbot/model"
+)
+
+func policyConfig(policy model.Policy) model.Config {
+	var cfg model.Config
+	cfg.Remind.TimeRange = model.TimeRange{Begin: "09:00:00+08:00", End: "18:00:00+08:00"}
+	cfg.Policy = policy
+	return cfg
+}
+
+func parseTime(t *testing.T, value string) time.Time {
+	result, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestNewCheckInWindow(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy model.Policy
+		t      string
+		begin  string
+		onTime string
+		end    string
+		err    bool
+	}{
+		{
+			name:   "default policy",
+			t:      "2021-03-01T12:00:00+08:00",
+			begin:  "2021-03-01T09:00:00+08:00",
+			onTime: "2021-03-01T18:00:00+08:00",
+			end:    "2021-03-01T18:00:00+08:00",
+		},
+		{
+			name:   "on time cutoff defaults to late",
+			policy: model.Policy{Late: "20:00:00+08:00"},
+			t:      "2021-03-01T12:00:00+08:00",
+			begin:  "2021-03-01T09:00:00+08:00",
+			onTime: "2021-03-01T20:00:00+08:00",
+			end:    "2021-03-01T20:00:00+08:00",
+		},
+		{
+			name:   "grace is added to on time cutoff",
+			policy: model.Policy{OnTime: "10:00:00+08:00", Grace: "5m"},
+			t:      "2021-03-01T12:00:00+08:00",
+			begin:  "2021-03-01T09:00:00+08:00",
+			onTime: "2021-03-01T10:05:00+08:00",
+			end:    "2021-03-01T18:00:00+08:00",
+		},
+		{
+			name:   "date is in Asia/Shanghai",
+			t:      "2021-02-28T20:00:00Z",
+			begin:  "2021-03-01T09:00:00+08:00",
+			onTime: "2021-03-01T18:00:00+08:00",
+			end:    "2021-03-01T18:00:00+08:00",
+		},
+		{
+			name:   "late window before begin",
+			policy: model.Policy{Late: "08:00:00+08:00"},
+			t:      "2021-03-01T12:00:00+08:00",
+			err:    true,
+		},
+		{
+			name:   "on time cutoff before begin",
+			policy: model.Policy{OnTime: "08:59:59+08:00"},
+			t:      "2021-03-01T12:00:00+08:00",
+			err:    true,
+		},
+		{
+			name:   "invalid grace",
+			policy: model.Policy{Grace: "5 minutes"},
+			t:      "2021-03-01T12:00:00+08:00",
+			err:    true,
+		},
+		{
+			name:   "invalid clock",
+			policy: model.Policy{OnTime: "10:00"},
+			t:      "2021-03-01T12:00:00+08:00",
+			err:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := newCheckInWindow(policyConfig(tt.policy), parseTime(t, tt.t))
+			if (err != nil) != tt.err {
+				t.Fatalf("error is %v, want error %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if !w.begin.Equal(parseTime(t, tt.begin)) {
+				t.Errorf("begin is %s, want %s", w.begin, tt.begin)
+			}
+			if !w.onTime.Equal(parseTime(t, tt.onTime)) {
+				t.Errorf("on time cutoff is %s, want %s", w.onTime, tt.onTime)
+			}
+			if !w.end.Equal(parseTime(t, tt.end)) {
+				t.Errorf("end is %s, want %s", w.end, tt.end)
+			}
+		})
+	}
+}
+
+func TestClassifyCheckIn(t *testing.T) {
+	policy := model.Policy{OnTime: "10:00:00+08:00", Grace: "5m", Late: "12:00:00+08:00"}
+	tests := []struct {
+		name   string
+		policy model.Policy
+		t      string
+		want   string
+	}{
+		{name: "before begin", policy: policy, t: "2021-03-01T08:59:59+08:00", want: ""},
+		{name: "at begin", policy: policy, t: "2021-03-01T09:00:00+08:00", want: model.CheckInOnTime},
+		{name: "at on time cutoff", policy: policy, t: "2021-03-01T10:00:00+08:00", want: model.CheckInOnTime},
+		{name: "end of grace", policy: policy, t: "2021-03-01T10:05:00+08:00", want: model.CheckInOnTime},
+		{name: "after grace", policy: policy, t: "2021-03-01T10:05:01+08:00", want: model.CheckInLate},
+		{name: "at end of late window", policy: policy, t: "2021-03-01T12:00:00+08:00", want: model.CheckInLate},
+		{name: "after late window", policy: policy, t: "2021-03-01T12:00:01+08:00", want: ""},
+		{name: "in UTC", policy: policy, t: "2021-03-01T02:30:00Z", want: model.CheckInLate},
+		{name: "without late window", t: "2021-03-01T18:00:00+08:00", want: model.CheckInOnTime},
+		{name: "after end of time range", t: "2021-03-01T18:00:01+08:00", want: ""},
+		{
+			name:   "grace beyond late window",
+			policy: model.Policy{OnTime: "11:58:00+08:00", Grace: "5m", Late: "12:00:00+08:00"},
+			t:      "2021-03-01T12:00:01+08:00",
+			want:   "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := classifyCheckIn(policyConfig(tt.policy), parseTime(t, tt.t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
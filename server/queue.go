@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/zhao-kun/reminder-tgbot/logging"
+	"github.com/zhao-kun/reminder-tgbot/model"
+	"github.com/zhao-kun/reminder-tgbot/repo"
+	"github.com/zhao-kun/reminder-tgbot/telegram"
+)
+
+const (
+	defaultWorkers   = 4
+	defaultQueueSize = 100
+
+	// updateTimeout limit handling an update, replies are canceled after it
+	updateTimeout = time.Minute
+)
+
+type (
+	// UpdateQueue handle updates asynchronously by a bounded worker pool,
+	// updates of the same chat are handled one by one in order
+	UpdateQueue interface {
+		// Push queue `update`, false is returned if the queue is full
+		Push(update model.TgMessage) bool
+	}
+
+	// updateQueue shard updates to workers by chat, each worker has its own
+	// queue
+	updateQueue struct {
+		queues []chan model.TgMessage
+	}
+)
+
+// NewUpdateQueue return an UpdateQueue which handle updates by `h` with
+// workers of `cfg`
+func NewUpdateQueue(cfg model.WorkerPool, h UpdateHandleFunc, c telegram.Client,
+	groups repo.Groups, l logging.Logger) (UpdateQueue, error) {
+	workers, size := cfg.Workers, cfg.QueueSize
+	if workers < 0 || size < 0 {
+		return nil, fmt.Errorf("workers %d and queue size %d should be positive",
+			workers, size)
+	}
+	if workers == 0 {
+		workers = defaultWorkers
+	}
+	if size == 0 {
+		size = defaultQueueSize
+	}
+
+	q := &updateQueue{}
+	perWorker := (size + workers - 1) / workers
+	for i := 0; i < workers; i++ {
+		queue := make(chan model.TgMessage, perWorker)
+		q.queues = append(q.queues, queue)
+		go func() {
+			for update := range queue {
+				ctx, cancel := context.WithTimeout(context.Background(), updateTimeout)
+				h(ctx, c, groups, l, update)
+				cancel()
+			}
+		}()
+	}
+	l.Info("update workers were started", logging.F("workers", workers),
+		logging.F("queue_size", perWorker*workers))
+	return q, nil
+}
+
+func (q *updateQueue) Push(update model.TgMessage) bool {
+	chatID, _ := updateSender(update)
+	queue := q.queues[uint64(chatID)%uint64(len(q.queues))]
+	select {
+	case queue <- update:
+		return true
+	default:
+		return false
+	}
+}
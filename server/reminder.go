@@ -0,0 +1,238 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/zhao-kun/reminder-tgbot/i18n"
+	"github.com/zhao-kun/reminder-tgbot/model"
+	"github.com/zhao-kun/reminder-tgbot/telegram"
+	"github.com/zhao-kun/reminder-tgbot/util"
+)
+
+// reminderPool choose messages of reminders from pools of escalation
+// levels, it's only used by the remind task of a group
+type reminderPool struct {
+	rand *rand.Rand
+	// date is the day of counts
+	date string
+	// counts is number of reminders sent to each user on date
+	counts map[string]int
+	// next is index of the next message of each level for round robin
+	next map[int]int
+	// last is index of the message chosen last time of each level, it's
+	// not chosen again randomly
+	last map[int]int
+}
+
+// newReminderPool return a reminderPool which choose messages randomly by
+// `src`
+func newReminderPool(src rand.Source) *reminderPool {
+	return &reminderPool{
+		rand:   rand.New(src),
+		counts: map[string]int{},
+		next:   map[int]int{},
+		last:   map[int]int{},
+	}
+}
+
+// choose return message of the next reminder to `user` at `t`, the level is
+// number of reminders sent to `user` on the day of `t`, ok is false if
+// `remind` doesn't have any pool
+func (p *reminderPool) choose(remind model.Remind, user string,
+	t time.Time) (msg model.ReminderMessage, ok bool) {
+	if len(remind.Levels) == 0 {
+		return msg, false
+	}
+	if date := util.GetDate(util.GetChinaTimeFromUnix(t.Unix())); date != p.date {
+		p.date = date
+		p.counts = map[string]int{}
+	}
+
+	level := p.counts[user]
+	p.counts[user]++
+	return p.pick(remind, level), true
+}
+
+// pick return a message from the pool of `level`, the last pool is used if
+// `level` is beyond it, `remind` should have pools. A message isn't chosen
+// twice in a row randomly if there are others
+func (p *reminderPool) pick(remind model.Remind, level int) model.ReminderMessage {
+	if level >= len(remind.Levels) {
+		level = len(remind.Levels) - 1
+	}
+	pool := remind.Levels[level]
+	var i int
+	switch last, ok := p.last[level]; {
+	case remind.Rotation == model.RotationRoundRobin:
+		i = p.next[level] % len(pool)
+		p.next[level] = i + 1
+	case ok && last < len(pool) && len(pool) > 1:
+		// skip the last one
+		if i = p.rand.Intn(len(pool) - 1); i >= last {
+			i++
+		}
+	default:
+		i = p.rand.Intn(len(pool))
+	}
+	p.last[level] = i
+	return pool[i]
+}
+
+// validateReminderLevels return error if pools of `remind` contain an
+// empty pool or an invalid message, or the rotation is unknown
+func validateReminderLevels(remind model.Remind) error {
+	switch remind.Rotation {
+	case "", model.RotationRandom, model.RotationRoundRobin:
+	default:
+		return fmt.Errorf("rotation %s is unknown, it should be %s or %s", remind.Rotation,
+			model.RotationRandom, model.RotationRoundRobin)
+	}
+
+	example := exampleTemplateData()
+	for level, pool := range remind.Levels {
+		if len(pool) == 0 {
+			return fmt.Errorf("reminder level %d is empty", level)
+		}
+		for i, msg := range pool {
+			if msg.Sticker != "" && msg.Animation != "" {
+				return fmt.Errorf("reminder %d of level %d has both sticker and animation",
+					i, level)
+			}
+			if msg.Text == "" && msg.Sticker == "" && msg.Animation == "" {
+				return fmt.Errorf("reminder %d of level %d is empty", i, level)
+			}
+			if _, err := execute(string(i18n.Reminder), msg.Text, example); err != nil {
+				return fmt.Errorf("reminder %d of level %d: %s", i, level, err)
+			}
+		}
+	}
+	return nil
+}
+
+// sendReminder send `msg` to chat `chatID`, the sticker is sent before the
+// text, and the text is caption of the animation
+func sendReminder(ctx context.Context, c telegram.Client, chatID int64,
+	msg model.ReminderMessage) error {
+	switch {
+	case msg.Animation != "":
+		return c.Animation(ctx, model.AnimationMessage{
+			ChatID:    chatID,
+			Animation: msg.Animation,
+			Caption:   msg.Text,
+		})
+	case msg.Sticker != "":
+		err := c.Sticker(ctx, model.StickerMessage{ChatID: chatID, Sticker: msg.Sticker})
+		if err != nil || msg.Text == "" {
+			return err
+		}
+	}
+	return c.Message(ctx, model.BotMessage{ChatID: chatID, Text: msg.Text})
+}
+
+// batchReminder is the consolidated reminder posted to a channel on date,
+// it mentions users who haven't checked in
+type batchReminder struct {
+	date      string
+	messageID int
+	users     []string
+	// level is the escalation level and msg is the message of pools it was
+	// posted with, msg is empty if there isn't any pool
+	level int
+	msg   model.ReminderMessage
+}
+
+// batchReminders are consolidated reminders of channels of a group, they're
+// only used by the remind task of the group
+type batchReminders struct {
+	pool  *reminderPool
+	chats map[int64]*batchReminder
+}
+
+func newBatchReminders(pool *reminderPool) *batchReminders {
+	return &batchReminders{pool: pool, chats: map[int64]*batchReminder{}}
+}
+
+// remind post a consolidated reminder which mentions `users` to chat
+// `chatID` at `t`, or edit the one posted on the day of `t` if `users`
+// changed. Text of the reminder is returned by `text` with the text of a
+// message of pools, `done` replace it when `users` is empty. If `remind`
+// has pools, a new reminder is posted for each escalation level, and the
+// sticker or animation is sent before it. Nothing is posted if `users` is
+// empty or `post` is false, sent is false if nothing was posted or edited
+func (b *batchReminders) remind(ctx context.Context, c telegram.Client,
+	remind model.Remind, chatID int64, users []string, t time.Time, post bool,
+	text func(tmpl string) string, done string) (sent bool, err error) {
+	date := util.GetDate(util.GetChinaTimeFromUnix(t.Unix()))
+	last, ok := b.chats[chatID]
+	if ok && last.date != date {
+		ok = false
+	}
+	escalate := ok && post && len(users) > 0 && last.level+1 < len(remind.Levels)
+	if ok && !escalate {
+		if equalUsers(last.users, users) {
+			return false, nil
+		}
+		edited := done
+		if len(users) > 0 {
+			edited = text(last.msg.Text)
+		}
+		err := c.Edit(ctx, model.EditMessage{
+			ChatID:    chatID,
+			MessageID: last.messageID,
+			Text:      edited,
+		})
+		if err == telegram.ErrNotModified {
+			// e.g. the template doesn't mention users
+			last.users = users
+			return false, nil
+		}
+		if err != nil {
+			// the message may be deleted, post a new one next time
+			delete(b.chats, chatID)
+			return false, err
+		}
+		last.users = users
+		return true, nil
+	}
+
+	if len(users) == 0 || !post {
+		return false, nil
+	}
+	next := batchReminder{date: date, users: users}
+	if escalate {
+		next.level = last.level + 1
+	}
+	if len(remind.Levels) > 0 {
+		next.msg = b.pool.pick(remind, next.level)
+	}
+	if next.msg.Sticker != "" || next.msg.Animation != "" {
+		// the text is sent as another message, so it can be edited
+		media := model.ReminderMessage{Sticker: next.msg.Sticker, Animation: next.msg.Animation}
+		if err := sendReminder(ctx, c, chatID, media); err != nil {
+			return false, err
+		}
+	}
+	if next.messageID, err = c.Post(ctx, model.BotMessage{
+		ChatID: chatID,
+		Text:   text(next.msg.Text),
+	}); err != nil {
+		return false, err
+	}
+	b.chats[chatID] = &next
+	return true, nil
+}
+
+func equalUsers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
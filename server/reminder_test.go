@@ -0,0 +1,264 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zhao-kun/reminder-tgbot/model"
+	"github.com/zhao-kun/reminder-tgbot/telegram"
+)
+
+func TestBatchRemindersRemind(t *testing.T) {
+	type step struct {
+		users []string
+		// day is days after the first step
+		day     int
+		post    bool
+		editErr error
+		sent    bool
+		err     bool
+		// posts, edits and stickers are numbers of messages sent until the
+		// step, text is of the last one posted or edited
+		posts    int
+		edits    int
+		stickers int
+		text     string
+	}
+	levels := [][]model.ReminderMessage{
+		{{Text: "first"}},
+		{{Text: "second", Sticker: "sticker"}},
+	}
+	tests := []struct {
+		name   string
+		levels [][]model.ReminderMessage
+		steps  []step
+	}{
+		{
+			name: "unchanged list",
+			steps: []step{
+				{users: []string{"a", "b"}, post: true, sent: true, posts: 1, text: ":a,b"},
+				{users: []string{"a", "b"}, post: true, posts: 1, text: ":a,b"},
+				{users: []string{"a", "b"}, posts: 1, text: ":a,b"},
+			},
+		},
+		{
+			name: "shrinking list",
+			steps: []step{
+				{users: []string{"a", "b"}, post: true, sent: true, posts: 1, text: ":a,b"},
+				{users: []string{"a"}, sent: true, posts: 1, edits: 1, text: ":a"},
+				{users: []string{"a"}, post: true, posts: 1, edits: 1, text: ":a"},
+				{sent: true, posts: 1, edits: 2, text: "done"},
+				{posts: 1, edits: 2, text: "done"},
+			},
+		},
+		{
+			name: "edit failing falls back to post",
+			steps: []step{
+				{users: []string{"a", "b"}, post: true, sent: true, posts: 1, text: ":a,b"},
+				{users: []string{"a"}, editErr: errors.New("message to edit not found"),
+					err: true, posts: 1, text: ":a,b"},
+				// it isn't posted until reminders are due again
+				{users: []string{"a"}, posts: 1, text: ":a,b"},
+				{users: []string{"a"}, post: true, sent: true, posts: 2, text: ":a"},
+			},
+		},
+		{
+			name: "message isn't modified",
+			steps: []step{
+				{users: []string{"a", "b"}, post: true, sent: true, posts: 1, text: ":a,b"},
+				{users: []string{"a"}, editErr: telegram.ErrNotModified, posts: 1, text: ":a,b"},
+				{users: []string{"a"}, posts: 1, text: ":a,b"},
+			},
+		},
+		{
+			name: "nothing is posted if it's not due or everyone checked in",
+			steps: []step{
+				{users: []string{"a"}},
+				{post: true},
+				{users: []string{"a"}, post: true, sent: true, posts: 1, text: ":a"},
+			},
+		},
+		{
+			name: "a new day",
+			steps: []step{
+				{users: []string{"a"}, post: true, sent: true, posts: 1, text: ":a"},
+				{users: []string{"a", "b"}, day: 1, post: true, sent: true, posts: 2, text: ":a,b"},
+			},
+		},
+		{
+			name:   "escalation threshold",
+			levels: levels,
+			steps: []step{
+				{users: []string{"a", "b"}, post: true, sent: true, posts: 1, text: "first:a,b"},
+				// it isn't escalated until reminders are due
+				{users: []string{"a", "b"}, posts: 1, text: "first:a,b"},
+				{users: []string{"a", "b"}, post: true, sent: true, posts: 2, stickers: 1,
+					text: "second:a,b"},
+				// the last level is edited instead
+				{users: []string{"a", "b"}, post: true, posts: 2, stickers: 1, text: "second:a,b"},
+				{users: []string{"a"}, post: true, sent: true, posts: 2, edits: 1, stickers: 1,
+					text: "second:a"},
+				{users: []string{"a"}, day: 1, post: true, sent: true, posts: 3, edits: 1,
+					stickers: 1, text: "first:a"},
+			},
+		},
+	}
+	begin := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &fakeClient{}
+			remind := model.Remind{Levels: tt.levels, Rotation: model.RotationRoundRobin}
+			b := newBatchReminders(newReminderPool(rand.NewSource(1)))
+			for i, s := range tt.steps {
+				c.editErr = s.editErr
+				text := func(tmpl string) string {
+					return tmpl + ":" + strings.Join(s.users, ",")
+				}
+				sent, err := b.remind(context.Background(), c, remind, 100, s.users,
+					begin.AddDate(0, 0, s.day), s.post, text, "done")
+				if (err != nil) != s.err {
+					t.Fatalf("step %d: error is %v, want error %v", i, err, s.err)
+				}
+				if sent != s.sent {
+					t.Errorf("step %d: sent is %v, want %v", i, sent, s.sent)
+				}
+				if len(c.posts) != s.posts || len(c.edits) != s.edits ||
+					len(c.stickers) != s.stickers {
+					t.Fatalf("step %d: %d posts, %d edits and %d stickers, want %d, %d and %d",
+						i, len(c.posts), len(c.edits), len(c.stickers), s.posts, s.edits,
+						s.stickers)
+				}
+				var got string
+				if len(c.texts) > 0 {
+					got = c.texts[len(c.texts)-1]
+				}
+				if got != s.text {
+					t.Errorf("step %d: text is %q, want %q", i, got, s.text)
+				}
+			}
+		})
+	}
+}
+
+func TestMention(t *testing.T) {
+	tests := []struct {
+		text  string
+		users []string
+		want  string
+	}{
+		{text: "Hi {{mention .Users}}", users: []string{"a"}, want: "Hi @a"},
+		{text: "Hi {{mention .Users}}", users: []string{"a", "b"}, want: "Hi @a, @b"},
+		{text: "Hi {{mention .Users}}", want: "Hi "},
+	}
+	for _, tt := range tests {
+		got, err := execute("reminder", tt.text, templateData{Users: tt.users})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
+
+// scriptedSource is a rand.Source whose Intn(n) return values in turn
+// modulo n
+type scriptedSource struct {
+	values []int64
+	i      int
+}
+
+func (s *scriptedSource) Int63() int64 {
+	v := s.values[s.i%len(s.values)]
+	s.i++
+	// Intn use the highest 31 bits
+	return v << 32
+}
+
+func (s *scriptedSource) Seed(seed int64) {}
+
+func TestReminderPoolPick(t *testing.T) {
+	abc := [][]model.ReminderMessage{{{Text: "a"}, {Text: "b"}, {Text: "c"}}}
+	tests := []struct {
+		name     string
+		levels   [][]model.ReminderMessage
+		rotation string
+		random   []int64
+		picks    []int
+		want     string
+	}{
+		{name: "random", levels: abc, random: []int64{2, 0, 1}, want: "cac"},
+		{name: "random doesn't repeat", levels: abc, random: []int64{0}, want: "abababa"},
+		{name: "random skip the last one", levels: abc, random: []int64{1, 1, 1}, want: "bcb"},
+		{
+			name:   "random with one message",
+			levels: [][]model.ReminderMessage{{{Text: "a"}}},
+			random: []int64{0},
+			want:   "aaa",
+		},
+		{name: "round robin", levels: abc, rotation: model.RotationRoundRobin, random: []int64{2},
+			want: "abcab"},
+		{
+			name: "levels are rotated separately",
+			levels: [][]model.ReminderMessage{
+				{{Text: "a"}, {Text: "b"}},
+				{{Text: "x"}, {Text: "y"}},
+			},
+			rotation: model.RotationRoundRobin,
+			random:   []int64{0},
+			picks:    []int{0, 1, 1, 0, 5},
+			want:     "axybx",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newReminderPool(&scriptedSource{values: tt.random})
+			remind := model.Remind{Levels: tt.levels, Rotation: tt.rotation}
+			levels := tt.picks
+			if levels == nil {
+				levels = make([]int, len(tt.want))
+			}
+			var got string
+			for _, level := range levels {
+				got += p.pick(remind, level).Text
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReminderPoolChoose(t *testing.T) {
+	remind := model.Remind{
+		Levels:   [][]model.ReminderMessage{{{Text: "first"}}, {{Text: "second"}}},
+		Rotation: model.RotationRoundRobin,
+	}
+	day := time.Date(2021, 3, 1, 2, 0, 0, 0, time.UTC)
+	tests := []struct {
+		user string
+		t    time.Time
+		want string
+	}{
+		{user: "a", t: day, want: "first"},
+		{user: "a", t: day.Add(time.Hour), want: "second"},
+		{user: "b", t: day.Add(time.Hour), want: "first"},
+		{user: "a", t: day.Add(2 * time.Hour), want: "second"},
+		// counts are reset on the next day of Asia/Shanghai
+		{user: "a", t: day.Add(22 * time.Hour), want: "first"},
+	}
+	p := newReminderPool(&scriptedSource{values: []int64{0}})
+	for i, tt := range tests {
+		msg, ok := p.choose(remind, tt.user, tt.t)
+		if !ok || msg.Text != tt.want {
+			t.Errorf("%d: got %q and %v, want %q", i, msg.Text, ok, tt.want)
+		}
+	}
+	if _, ok := p.choose(model.Remind{}, "a", day); ok {
+		t.Error("message is chosen without pools")
+	}
+}
@@ -0,0 +1,204 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/zhao-kun/reminder-tgbot/i18n"
+	"github.com/zhao-kun/reminder-tgbot/logging"
+	"github.com/zhao-kun/reminder-tgbot/metrics"
+	"github.com/zhao-kun/reminder-tgbot/model"
+	"github.com/zhao-kun/reminder-tgbot/repo"
+	"github.com/zhao-kun/reminder-tgbot/telegram"
+	"github.com/zhao-kun/reminder-tgbot/util"
+)
+
+// role is who is allowed to run a command
+type role int
+
+const (
+	// roleAnyone allow everyone to run a command
+	roleAnyone role = iota
+	// roleAdmin only allow `admins` of the group to run a command
+	roleAdmin
+)
+
+type (
+	// parseArgsFunc parse arguments following a command, the result is
+	// passed to processCommandFunc, error is replied with usage of the
+	// command
+	parseArgsFunc func(args []string) (interface{}, error)
+
+	// argsError is an error of arguments which is replied in the language
+	// of the sender
+	argsError i18n.Key
+
+	// command is a bot command registered to commandRouter, its description
+	// and usage are i18n.Description and i18n.Usage of the name
+	command struct {
+		// name is the command with leading slash, e.g. `/checkin`
+		name string
+		role role
+		// parseArgs is optional, arguments are passed as []string if it's nil
+		parseArgs  parseArgsFunc
+		validators []validateFunc
+		process    processCommandFunc
+	}
+
+	// commandRouter route a command to its processCommandFunc after the
+	// sender is authorized and the command is validated
+	commandRouter struct {
+		commands []command
+		index    map[string]int
+	}
+)
+
+func (e argsError) Error() string {
+	return i18n.T(nil, i18n.English, i18n.Key(e))
+}
+
+// errorText return `err` in the language of `from` if it's an argsError or
+// a known error of repo
+func errorText(r repo.Repo, from model.From, err error) string {
+	if e, ok := err.(argsError); ok {
+		return tr(r, from, i18n.Key(e))
+	}
+	switch err {
+	case repo.ErrCheckInNotFound:
+		return tr(r, from, i18n.CheckInNotFound)
+	case repo.ErrInvalidUser:
+		return tr(r, from, i18n.InvalidUser)
+	}
+	return err.Error()
+}
+
+func newCommandRouter() *commandRouter {
+	return &commandRouter{index: map[string]int{}}
+}
+
+// register add `cmd` to router, it panics if the name is registered already
+func (rt *commandRouter) register(cmd command) {
+	if !strings.HasPrefix(cmd.name, "/") || cmd.process == nil {
+		panic(fmt.Sprintf("command %s is invalid", cmd.name))
+	}
+	if _, ok := rt.index[cmd.name]; ok {
+		panic(fmt.Sprintf("command %s is registered already", cmd.name))
+	}
+	if cmd.role == roleAdmin {
+		cmd.validators = append([]validateFunc{countRejection("admin", validateAdmin)},
+			cmd.validators...)
+	}
+	rt.index[cmd.name] = len(rt.commands)
+	rt.commands = append(rt.commands, cmd)
+}
+
+func (rt *commandRouter) lookup(name string) (command, bool) {
+	i, ok := rt.index[name]
+	if !ok {
+		return command{}, false
+	}
+	return rt.commands[i], true
+}
+
+// route return a commandFunc which run the command of `msg`, nil is
+// returned if `msg` isn't a registered command addressed to bot `botName`
+func (rt *commandRouter) route(botName string, msg model.Message) commandFunc {
+	if msg.MessageID <= 0 {
+		return nil
+	}
+	parsed, ok := parseCommand(msg)
+	if !ok || !parsed.addressedTo(botName) {
+		return nil
+	}
+	cmd, ok := rt.lookup(parsed.name)
+	if !ok {
+		return nil
+	}
+
+	metrics.CommandsDispatched.Inc(cmd.name)
+	return func(ctx context.Context, c telegram.Client, r repo.Repo, l logging.Logger) error {
+		l = l.With(logging.F("command", cmd.name))
+		for _, validFunc := range cmd.validators {
+			valid, tips := validFunc(r, l, msg)
+			if !valid {
+				l.Info("command was rejected", logging.F("tips", tips))
+				return c.Reply(ctx, newReplyMessage(msg.Chat.ID, msg.MessageID, tips))
+			}
+		}
+
+		var args interface{} = parsed.args
+		if cmd.parseArgs != nil {
+			var err error
+			if args, err = cmd.parseArgs(parsed.args); err != nil {
+				l.Info("arguments are invalid", logging.Err(err))
+				return c.Reply(ctx, newReplyMessage(msg.Chat.ID, msg.MessageID,
+					tr(r, msg.From, i18n.ArgsInvalid, errorText(r, msg.From, err),
+						tr(r, msg.From, i18n.Usage(cmd.name)))))
+			}
+		}
+		return cmd.process(r, l, msg, args).send(ctx, c)
+	}
+}
+
+// botCommands return commands which are shown in the command menu of
+// Telegram in language `lang`, admin commands are excluded
+func (rt *commandRouter) botCommands(overrides map[string]map[string]string,
+	lang string) []model.BotCommand {
+	var commands []model.BotCommand
+	for _, cmd := range rt.commands {
+		if cmd.role != roleAnyone {
+			continue
+		}
+		commands = append(commands, model.BotCommand{
+			Command:     strings.TrimPrefix(cmd.name, "/"),
+			Description: i18n.T(overrides, lang, i18n.Description(cmd.name)),
+		})
+	}
+	return commands
+}
+
+// helpFunc return a processCommandFunc which list commands of `rt`, admin
+// commands are only listed for admins
+func helpFunc(rt *commandRouter) processCommandFunc {
+	return func(r repo.Repo, l logging.Logger, msg model.Message, args interface{}) reply {
+		isAdmin := util.StrInSlice(msg.From.Username, r.Cfg().Admins)
+		lines := []string{tr(r, msg.From, i18n.Commands)}
+		var adminLines []string
+		for _, cmd := range rt.commands {
+			line := fmt.Sprintf("%s - %s", tr(r, msg.From, i18n.Usage(cmd.name)),
+				tr(r, msg.From, i18n.Description(cmd.name)))
+			switch {
+			case cmd.role == roleAnyone:
+				lines = append(lines, line)
+			case isAdmin:
+				adminLines = append(adminLines, line)
+			}
+		}
+		if len(adminLines) > 0 {
+			lines = append(lines, "", tr(r, msg.From, i18n.AdminCommands))
+			lines = append(lines, adminLines...)
+		}
+		return textReply(newReplyMessage(msg.Chat.ID, msg.MessageID,
+			strings.Join(lines, "\n")))
+	}
+}
+
+// RegisterCommands set the command menu of the bot by Telegram
+// `setMyCommands` for each language, it's generated from registered
+// commands, descriptions of `overrides` take precedence over builtin ones.
+// The menu of the default language is shown for other languages
+func RegisterCommands(ctx context.Context, c telegram.Client,
+	overrides map[string]map[string]string) error {
+	for i, lang := range i18n.Languages {
+		// Telegram identify a language by two letters, e.g. `zh`
+		code := strings.SplitN(lang, "-", 2)[0]
+		if i == 0 {
+			code = ""
+		}
+		if err := c.SetCommands(ctx, commands.botCommands(overrides, lang), code); err != nil {
+			return err
+		}
+	}
+	return nil
+}
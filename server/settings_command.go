@@ -0,0 +1,250 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zhao-kun/reminder-tgbot/i18n"
+	"github.com/zhao-kun/reminder-tgbot/logging"
+	"github.com/zhao-kun/reminder-tgbot/model"
+	"github.com/zhao-kun/reminder-tgbot/repo"
+	"github.com/zhao-kun/reminder-tgbot/telegram"
+)
+
+const (
+	// settingsCallback is prefix of callback data of buttons of `/settings`,
+	// e.g. `settings:dm:on`, `settings:lang:zh-CN` and `settings:quiet:6`
+	settingsCallback = "settings"
+	settingDM        = "dm"
+	settingLanguage  = "lang"
+	settingQuietDay  = "quiet"
+
+	privateChat = "private"
+)
+
+// languages can be chosen by `/settings`, they're named in their own
+// language, the first one is the default
+var languages = []struct {
+	code string
+	name string
+}{
+	{i18n.English, "English"},
+	{i18n.Chinese, "简体中文"},
+}
+
+// weekdays are shown by `/settings` in this order
+var weekdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday,
+	time.Thursday, time.Friday, time.Saturday, time.Sunday}
+
+func validatePrivate(r repo.Repo, l logging.Logger, message model.Message) (valid bool, tips string) {
+	return message.Chat.Type == privateChat, tr(r, message.From, i18n.PrivateOnly)
+}
+
+// isQuietDay return true if weekday `d` is one of quiet days of `us`
+func isQuietDay(us model.UserSettings, d time.Weekday) bool {
+	for _, day := range us.QuietDays {
+		if day == d {
+			return true
+		}
+	}
+	return false
+}
+
+// processStart link the private chat to the sender, so reminders can be sent
+// to it
+func processStart(r repo.Repo, l logging.Logger, msg model.Message, args interface{}) reply {
+	user := msg.From.Username
+	resp := newReplyMessage(msg.Chat.ID, msg.MessageID, "")
+	if !isNeedCheckIn(r.Cfg().CheckUesrs, user) {
+		resp.Text = tr(r, msg.From, i18n.StartNotTracked, user)
+		return textReply(resp)
+	}
+
+	us := r.Settings(user)
+	us.ChatID = msg.Chat.ID
+	if err := r.SaveSettings(user, us); err != nil {
+		l.Error("save settings failed", logging.Err(err))
+		resp.Text = tr(r, msg.From, i18n.SaveSettingsFailed)
+		return textReply(resp)
+	}
+	l.Info("private chat was linked")
+	resp.Text = tr(r, msg.From, i18n.Linked, user, r.Cfg().Group)
+	return textReply(resp)
+}
+
+func processSettings(r repo.Repo, l logging.Logger, msg model.Message, args interface{}) reply {
+	user := msg.From.Username
+	resp := newReplyMessage(msg.Chat.ID, msg.MessageID, "")
+	us := r.Settings(user)
+	if us.ChatID != msg.Chat.ID {
+		resp.Text = tr(r, msg.From, i18n.StartFirst)
+		return textReply(resp)
+	}
+	resp.Text = settingsText(r, msg.From, us)
+	resp.ReplyMarkup = settingsKeyboard(r, msg.From, us)
+	return textReply(resp)
+}
+
+// onOff return translated `on` or `off`
+func onOff(r repo.Repo, from model.From, on bool) string {
+	if on {
+		return tr(r, from, i18n.On)
+	}
+	return tr(r, from, i18n.Off)
+}
+
+// settingsText describe personal settings `us` of `from` in the language
+// of `from`
+func settingsText(r repo.Repo, from model.From, us model.UserSettings) string {
+	language := languages[0].name
+	for _, lang := range languages {
+		if lang.code == us.Language {
+			language = lang.name
+		}
+	}
+	quiet := tr(r, from, i18n.None)
+	if len(us.QuietDays) > 0 {
+		var days []string
+		for _, d := range us.QuietDays {
+			days = append(days, tr(r, from, i18n.Weekday(d)))
+		}
+		quiet = strings.Join(days, ", ")
+	}
+	return tr(r, from, i18n.Settings, from.Username, onOff(r, from, us.RemindDM),
+		language, quiet)
+}
+
+// settingsKeyboard return buttons which change personal settings `us` of
+// `from`, current values are checked
+func settingsKeyboard(r repo.Repo, from model.From,
+	us model.UserSettings) *model.InlineKeyboardMarkup {
+	check := func(checked bool, text string) string {
+		if checked {
+			return "✓ " + text
+		}
+		return text
+	}
+
+	dm := model.InlineKeyboardButton{
+		Text:         tr(r, from, i18n.RemindDMButton, onOff(r, from, us.RemindDM)),
+		CallbackData: settingsCallbackData(settingDM, "on"),
+	}
+	if us.RemindDM {
+		dm.CallbackData = settingsCallbackData(settingDM, "off")
+	}
+
+	var langRow []model.InlineKeyboardButton
+	for i, lang := range languages {
+		checked := lang.code == us.Language || (us.Language == "" && i == 0)
+		langRow = append(langRow, model.InlineKeyboardButton{
+			Text:         check(checked, lang.name),
+			CallbackData: settingsCallbackData(settingLanguage, lang.code),
+		})
+	}
+
+	var quietRow []model.InlineKeyboardButton
+	for _, d := range weekdays {
+		quietRow = append(quietRow, model.InlineKeyboardButton{
+			Text:         check(isQuietDay(us, d), tr(r, from, i18n.Weekday(d))),
+			CallbackData: settingsCallbackData(settingQuietDay, strconv.Itoa(int(d))),
+		})
+	}
+
+	return &model.InlineKeyboardMarkup{
+		InlineKeyboard: [][]model.InlineKeyboardButton{{dm}, langRow, quietRow},
+	}
+}
+
+func settingsCallbackData(setting, value string) string {
+	return strings.Join([]string{settingsCallback, setting, value}, ":")
+}
+
+// toggleQuietDay add `d` to quiet days of `us` or remove it, quiet days are
+// kept sorted
+func toggleQuietDay(us model.UserSettings, d time.Weekday) model.UserSettings {
+	var days []time.Weekday
+	found := false
+	for _, day := range us.QuietDays {
+		if day == d {
+			found = true
+			continue
+		}
+		days = append(days, day)
+	}
+	if !found {
+		days = append(days, d)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i] < days[j] })
+	us.QuietDays = days
+	return us
+}
+
+// processSettingsCallback change a personal setting of the user who pressed
+// the button of `/settings`
+func processSettingsCallback(ctx context.Context, c telegram.Client, r repo.Repo,
+	l logging.Logger, query model.CallbackQuery) error {
+	answer := model.CallbackAnswer{CallbackQueryID: query.ID}
+	user := query.From.Username
+	us := r.Settings(user)
+	if user == "" || us.ChatID != query.Message.Chat.ID {
+		answer.Text = tr(r, query.From, i18n.StartFirst)
+		return c.AnswerCallback(ctx, answer)
+	}
+
+	parts := strings.SplitN(query.Data, ":", 3)
+	if len(parts) != 3 {
+		answer.Text = tr(r, query.From, i18n.InvalidRequest)
+		return c.AnswerCallback(ctx, answer)
+	}
+	setting, value := parts[1], parts[2]
+	switch setting {
+	case settingDM:
+		us.RemindDM = value == "on"
+	case settingLanguage:
+		us.Language = ""
+		for _, lang := range languages {
+			if lang.code == value {
+				us.Language = value
+			}
+		}
+	case settingQuietDay:
+		d, err := strconv.Atoi(value)
+		if err != nil || d < int(time.Sunday) || d > int(time.Saturday) {
+			answer.Text = tr(r, query.From, i18n.InvalidRequest)
+			return c.AnswerCallback(ctx, answer)
+		}
+		us = toggleQuietDay(us, time.Weekday(d))
+	default:
+		answer.Text = tr(r, query.From, i18n.InvalidRequest)
+		return c.AnswerCallback(ctx, answer)
+	}
+
+	if err := r.SaveSettings(user, us); err != nil {
+		l.Error("save settings failed", logging.Err(err))
+		answer.Text = tr(r, query.From, i18n.SaveSettingsFailed)
+		return c.AnswerCallback(ctx, answer)
+	}
+	l.Info("settings were changed", logging.F("setting", setting))
+
+	err := c.Edit(ctx, model.EditMessage{
+		ChatID:      query.Message.Chat.ID,
+		MessageID:   query.Message.MessageID,
+		Text:        settingsText(r, query.From, us),
+		ReplyMarkup: settingsKeyboard(r, query.From, us),
+	})
+	if err == telegram.ErrNotModified {
+		err = nil
+	}
+	if err != nil {
+		answer.Text = tr(r, query.From, i18n.EditFailed)
+	}
+	// the button keeps loading until the callback is answered, so it's
+	// answered even if the message can't be edited
+	if answerErr := c.AnswerCallback(ctx, answer); err == nil {
+		err = answerErr
+	}
+	return err
+}
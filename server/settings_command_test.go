@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zhao-kun/reminder-tgbot/i18n"
+	"github.com/zhao-kun/reminder-tgbot/model"
+	"github.com/zhao-kun/reminder-tgbot/telegram"
+)
+
+func TestProcessSettingsCallback(t *testing.T) {
+	started := model.UserSettings{ChatID: 300, QuietDays: []time.Weekday{time.Saturday}}
+	tests := []struct {
+		name    string
+		user    string
+		data    string
+		editErr error
+		want    model.UserSettings
+		answer  string
+		edited  bool
+		err     bool
+	}{
+		{name: "turn on reminders in private chat", user: "alice", data: "settings:dm:on",
+			want: model.UserSettings{ChatID: 300, RemindDM: true,
+				QuietDays: []time.Weekday{time.Saturday}}, edited: true},
+		{name: "toggle a quiet day", user: "alice", data: "settings:quiet:0",
+			want: model.UserSettings{ChatID: 300,
+				QuietDays: []time.Weekday{time.Sunday, time.Saturday}}, edited: true},
+		{name: "invalid quiet day", user: "alice", data: "settings:quiet:7", want: started,
+			answer: i18n.T(nil, "", i18n.InvalidRequest)},
+		{name: "unknown setting", user: "alice", data: "settings:theme:dark", want: started,
+			answer: i18n.T(nil, "", i18n.InvalidRequest)},
+		{name: "not started", user: "bob", data: "settings:dm:on",
+			answer: i18n.T(nil, "", i18n.StartFirst)},
+		{name: "message isn't modified", user: "alice", data: "settings:dm:off",
+			editErr: telegram.ErrNotModified, want: started},
+		{name: "edit failed", user: "alice", data: "settings:dm:on",
+			editErr: errors.New("message to edit not found"),
+			want: model.UserSettings{ChatID: 300, RemindDM: true,
+				QuietDays: []time.Weekday{time.Saturday}},
+			answer: i18n.T(nil, "", i18n.EditFailed), err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, cleanup := testRepo(t, model.Config{})
+			defer cleanup()
+			if err := r.SaveSettings("alice", started); err != nil {
+				t.Fatal(err)
+			}
+			c := &fakeClient{editErr: tt.editErr}
+			query := model.CallbackQuery{
+				ID:      "query",
+				From:    model.From{ID: 1, Username: tt.user},
+				Message: model.Message{MessageID: 7, Chat: model.Chat{ID: 300, Type: privateChat}},
+				Data:    tt.data,
+			}
+			err := handleCallback(context.Background(), c, r, testLogger(t), query)
+			if (err != nil) != tt.err {
+				t.Fatalf("error is %v, want error %v", err, tt.err)
+			}
+			if len(c.answers) != 1 || c.answers[0].Text != tt.answer {
+				t.Fatalf("answers are %+v, want %q", c.answers, tt.answer)
+			}
+			if edited := len(c.edits) == 1; edited != tt.edited {
+				t.Errorf("message is edited %v, want %v", edited, tt.edited)
+			}
+			if got := r.Settings(tt.user); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("settings are %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
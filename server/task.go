@@ -1,12 +1,16 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"math/rand"
 	"time"
 
 	"github.com/zhao-kun/reminder-tgbot/client"
+	"github.com/zhao-kun/reminder-tgbot/i18n"
+	"github.com/zhao-kun/reminder-tgbot/logging"
+	"github.com/zhao-kun/reminder-tgbot/metrics"
 	"github.com/zhao-kun/reminder-tgbot/model"
 	"github.com/zhao-kun/reminder-tgbot/repo"
 	"github.com/zhao-kun/reminder-tgbot/task"
@@ -14,103 +18,228 @@ import (
 	"github.com/zhao-kun/reminder-tgbot/util"
 )
 
+const (
+	calendarTaskName = "get_chinese_festival_task"
+	remindTaskName   = "remind_task"
+)
+
+// botTaskFunc is a task func which need telegram.Client and repo.Repo,
+// requests of it are canceled when the context is done
+type botTaskFunc func(context.Context, telegram.Client, repo.Repo, logging.Logger,
+	task.Context) bool
+
 // wrapWithRepoAndTelegramClient wrap function with model.Config and
 // telegram.Client function to a TaskCallbackFunc
-func wrapWithRepoAndTelegramClient(tgClient telegram.Client, r repo.Repo,
-	c task.Context, f func(telegram.Client, repo.Repo, task.Context) bool) task.CallbackFunc {
+func wrapWithRepoAndTelegramClient(ctx context.Context, tgClient telegram.Client,
+	r repo.Repo, l logging.Logger, c task.Context, f botTaskFunc) task.CallbackFunc {
 	return func() bool {
-		return f(tgClient, r, c)
+		return f(ctx, tgClient, r, l, c)
 	}
 }
 
-func getChineseFestivalCalendar(c telegram.Client, r repo.Repo, context task.Context) bool {
-	if !isChinaTimeZoneNewDay() {
+// getChineseFestivalCalendar return a task func which look up calendar
+// service by `hc`
+func getChineseFestivalCalendar(hc client.Client) botTaskFunc {
+	return func(ctx context.Context, c telegram.Client, r repo.Repo, l logging.Logger,
+		tc task.Context) bool {
+		// look up calendar again if it failed today
+		if !isChinaTimeZoneNewDay() && calendarOf(r.Cfg().Group).updatedToday() {
+			return true
+		}
+		tc[contextTodayIsFestivalKey] = 0
+		tc[contextTodayIsFestivalKey] = todayIsFestival(ctx, hc, l, r.Cfg())
+		l.Info("calendar was updated", logging.F("festival", tc[contextTodayIsFestivalKey]))
 		return true
 	}
-	context[contextTodayIsFestivalKey] = 0
-	context[contextTodayIsFestivalKey] = todayIsFestival(r.Cfg().CNCalendarServiceEndpoint)
-	log.Printf("today is [%d] day", context[contextTodayIsFestivalKey])
-	return true
 }
 
-func todayIsFestival(calendarServiceEndpoint string) int {
+// todayIsFestival look up calendar service of `cfg`, calendar status of the
+// group of `cfg` is updated if it succeeded
+func todayIsFestival(ctx context.Context, hc client.Client, l logging.Logger,
+	cfg model.Config) int {
 	today := util.GetDate(util.GetChinaTimeNow())
-	url := fmt.Sprintf("%s?date=%s", calendarServiceEndpoint, today)
-	resp, err := client.HandleRequest("GET", url, nil)
+	url := fmt.Sprintf("%s?date=%s", cfg.CNCalendarServiceEndpoint, today)
+	resp, err := hc.HandleRequest(ctx, "GET", url, nil)
 	if err != nil {
-		log.Printf("request %s failed: %s", url, err)
+		l.Error("request calendar service failed", logging.F("url", url), logging.Err(err))
 		return 0
 	}
 
 	var cal calendarResp
 	err = json.Unmarshal(resp, &cal)
 	if err != nil {
-		log.Printf("unmarsh resp %+v failed: %s", resp, err)
+		l.Error("unmarshal calendar response failed", logging.F("body", resp),
+			logging.Err(err))
 		return 0
 	}
 
+	calendarOf(cfg.Group).update(time.Now(), cal.Data <= 0)
 	return cal.Data
 }
 
-func isWorkDay(context task.Context) bool {
-	value := context[contextTodayIsFestivalKey]
+func isWorkDay(l logging.Logger, tc task.Context) bool {
+	value := tc[contextTodayIsFestivalKey]
 	workday, ok := value.(int)
 	if !ok {
-		log.Printf("WARN: the context value[%+v] of [%s] is not int type",
-			value, contextTodayIsFestivalKey)
+		l.Warn("context value is not int type", logging.F("key", contextTodayIsFestivalKey),
+			logging.F("value", value))
 		workday = 0
 	}
 	return workday <= 0
 }
 
-func reminder(c telegram.Client, r repo.Repo, context task.Context) bool {
+// newReminder return the remind task func, a consolidated reminder is
+// posted to each channel and edited as users check in, reminders are
+// chosen from pools of escalation levels if they're configured
+func newReminder() botTaskFunc {
+	pool := newReminderPool(rand.NewSource(time.Now().UnixNano()))
+	batches := newBatchReminders(pool)
+	return func(ctx context.Context, c telegram.Client, r repo.Repo, l logging.Logger,
+		tc task.Context) bool {
+		return remind(ctx, c, r, l, tc, pool, batches)
+	}
+}
+
+func remind(ctx context.Context, c telegram.Client, r repo.Repo, l logging.Logger,
+	tc task.Context, pool *reminderPool, batches *batchReminders) bool {
+	now := time.Now()
+	remindTime, err := isRemindTime(now, r.Cfg().Remind.TimeRange.Begin,
+		r.Cfg().Remind.TimeRange.End)
+	if err != nil {
+		l.Error("check remind time failed", logging.Err(err))
+	}
+	// reminders are only posted in the reminder time range of work days,
+	// but reminders posted today are still edited as users check in
+	due := isWorkDay(l, tc) && remindTime
+
+	var pending []string
 	for _, u := range r.Cfg().CheckUesrs {
 		if !r.IsUserNeedCheckIn(u) {
 			continue
 		}
-
-		if !isWorkDay(context) ||
-			!isRemindTime(time.Now(),
-				r.Cfg().Remind.TimeRange.Begin,
-				r.Cfg().Remind.TimeRange.End) {
-			// no work day and no reminder time range
+		settings := r.Settings(u)
+		if isQuietDay(settings, util.GetChinaTimeNow().Weekday()) {
 			continue
 		}
+		pending = append(pending, u)
+		if due && settings.RemindDM && settings.ChatID != 0 {
+			remindUser(ctx, c, r, l, pool, u, settings, now)
+		}
+	}
 
-		for _, chatID := range r.Cfg().Channels {
-			message := model.BotMessage{
-				ChatID: chatID,
-				Text:   fmt.Sprintf("Hi @%s, you need to check in now", u),
-			}
-			if err := c.Message(message); err != nil {
-				log.Printf("send message %+v to channel %d failed: %s", message, chatID, err)
-			}
+	lang := r.Cfg().Language
+	done := i18n.T(r.Cfg().Messages, lang, i18n.AllCheckedIn)
+	text := func(tmpl string) string {
+		data := newTemplateData(r, "", now)
+		data.Users = pending
+		fallback := render(r, l, i18n.BatchReminder, data, i18n.T(r.Cfg().Messages, lang,
+			i18n.BatchReminder, mention(pending)))
+		if tmpl == "" {
+			return fallback
+		}
+		text, err := execute(string(i18n.Reminder), tmpl, data)
+		if err != nil {
+			l.Error("render reminder failed", logging.Err(err))
+			return fallback
+		}
+		return text
+	}
+	for _, chatID := range r.Cfg().Channels {
+		sent, err := batches.remind(ctx, c, r.Cfg().Remind, chatID,
+			pending, now, due, text, done)
+		if err != nil {
+			metrics.RemindersFailed.Inc()
+			l.Error("send reminder failed", logging.ChatID(chatID), logging.Err(err))
+			continue
+		}
+		if sent {
+			metrics.RemindersSent.Inc()
 		}
 	}
 	return true
 }
 
-// StartAllBotTask start task which need be run by the bot
-func StartAllBotTask(c telegram.Client, r repo.Repo) error {
-	context := task.NewContext()
+// remindUser send a reminder to the private chat of `u`
+func remindUser(ctx context.Context, c telegram.Client, r repo.Repo, l logging.Logger,
+	pool *reminderPool, u string, settings model.UserSettings, now time.Time) {
+	data := newTemplateData(r, u, now)
+	fallback := render(r, l, i18n.Reminder, data,
+		i18n.T(r.Cfg().Messages, settings.Language, i18n.Reminder, u))
+	msg, ok := pool.choose(r.Cfg().Remind, u, now)
+	var err error
+	if !ok {
+		msg = model.ReminderMessage{Text: fallback}
+	} else if msg.Text, err = execute(string(i18n.Reminder), msg.Text, data); err != nil {
+		// pools were validated with example variables when bot started, but
+		// they may still fail with real ones
+		l.Error("render reminder failed", logging.User(u), logging.Err(err))
+		msg.Text = fallback
+	}
+
+	if err := sendReminder(ctx, c, settings.ChatID, msg); err != nil {
+		metrics.RemindersFailed.Inc()
+		l.Error("send reminder failed", logging.User(u), logging.ChatID(settings.ChatID),
+			logging.Err(err))
+		return
+	}
+	metrics.RemindersSent.Inc()
+}
+
+// StartAllBotTask start task which need be run by the bot for each group,
+// calendar service is requested by `hc`, requests of tasks are canceled when
+// `ctx` is done. The registry of started tasks is returned
+func StartAllBotTask(ctx context.Context, c telegram.Client, hc client.Client,
+	groups repo.Groups, l logging.Logger) (task.Registry, error) {
+	registry := task.NewTaskRegistry(l)
+	for _, r := range groups.All() {
+		if err := addGroupTasks(ctx, registry, c, hc, r, l); err != nil {
+			return nil, fmt.Errorf("group %s: %s", r.Cfg().Group, err)
+		}
+	}
+	registry.StartAllTask()
+	return registry, nil
+}
+
+// addGroupTasks add the calendar and remind task of the group of `r` to
+// `registry`, tasks of a group share a task.Context
+func addGroupTasks(ctx context.Context, registry task.Registry, c telegram.Client,
+	hc client.Client, r repo.Repo, l logging.Logger) error {
+	timeRange := r.Cfg().Remind.TimeRange
+	if _, err := isRemindTime(time.Now(), timeRange.Begin, timeRange.End); err != nil {
+		return fmt.Errorf("remind time range is invalid: %s", err)
+	}
+	if _, err := classifyCheckIn(r.Cfg(), time.Now()); err != nil {
+		return fmt.Errorf("check in policy is invalid: %s", err)
+	}
+	if err := validateReminderLevels(r.Cfg().Remind); err != nil {
+		return fmt.Errorf("reminder levels are invalid: %s", err)
+	}
 
-	context[contextTodayIsFestivalKey] = todayIsFestival(r.Cfg().CNCalendarServiceEndpoint)
-	log.Printf("Today is %d", context[contextTodayIsFestivalKey])
+	group := r.Cfg().Group
+	l = l.With(logging.F("group", group))
+	calendarName := groupName(calendarTaskName, group)
+	remindName := groupName(remindTaskName, group)
 
-	calendarTask, err := task.New("get_chinese_festival_task", "2m",
-		wrapWithRepoAndTelegramClient(c, r, context, getChineseFestivalCalendar))
+	tc := task.NewContext()
+
+	tc[contextTodayIsFestivalKey] = todayIsFestival(ctx, hc,
+		l.With(logging.Task(calendarName)), r.Cfg())
+	l.Info("calendar was loaded", logging.F("festival", tc[contextTodayIsFestivalKey]))
+
+	calendarTask, err := task.New(calendarName, "2m",
+		wrapWithRepoAndTelegramClient(ctx, c, r, l.With(logging.Task(calendarName)), tc,
+			getChineseFestivalCalendar(hc)))
 	if err != nil {
 		return fmt.Errorf("create calendarTask error: %s", err)
 	}
 
-	remindTask, err := task.New("remind_task", r.Cfg().Remind.RemindInterval,
-		wrapWithRepoAndTelegramClient(c, r, context, reminder))
+	remindTask, err := task.New(remindName, r.Cfg().Remind.RemindInterval,
+		wrapWithRepoAndTelegramClient(ctx, c, r, l.With(logging.Task(remindName)), tc,
+			newReminder()))
 	if err != nil {
 		return fmt.Errorf("create remindTask error: %s", err)
 	}
 
-	registry := task.NewTaskRegistry()
-
 	err = registry.AddTask(calendarTask)
 	if err != nil {
 		return fmt.Errorf("Add %s task error: %s", calendarTask.Name(), err)
@@ -119,6 +248,5 @@ func StartAllBotTask(c telegram.Client, r repo.Repo) error {
 	if err != nil {
 		return fmt.Errorf("Add %s task error: %s", remindTask.Name(), err)
 	}
-	registry.StartAllTask()
 	return nil
 }
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/zhao-kun/reminder-tgbot/i18n"
+	"github.com/zhao-kun/reminder-tgbot/logging"
+	"github.com/zhao-kun/reminder-tgbot/model"
+	"github.com/zhao-kun/reminder-tgbot/repo"
+	"github.com/zhao-kun/reminder-tgbot/util"
+)
+
+// streakDays limit how many days are looked back to count a streak
+const streakDays = 90
+
+// templateKinds is kinds of messages which can be customized by templates,
+// each one replace the message of i18n catalog with the same key in all
+// languages, `checked_in` replace `checked_in_late` too
+var templateKinds = []i18n.Key{
+	i18n.Reminder,
+	i18n.BatchReminder,
+	i18n.CheckedIn,
+	i18n.AlreadyCheckedIn,
+	i18n.OutOfWindow,
+	i18n.NotTracked,
+}
+
+type (
+	// templateData is variables of message templates, e.g. `{{.User}}`,
+	// `{{.Streak}}` is a method
+	templateData struct {
+		User string
+		// Time is when the message was sent or the reminder is sent
+		Time time.Time
+		// streak count the streak, it's only called by templates using it
+		streak func() int
+		Window templateWindow
+		// Users are users the message is about, they're users who haven't
+		// checked in for `batch_reminder` and pools in channels, User is
+		// empty for them. It's User alone for other messages
+		Users []string
+		// Kind and Status is of the check in, they're only set for
+		// `checked_in`
+		Kind   string
+		Status string
+	}
+
+	// templateWindow is the check in window of the day of Time
+	templateWindow struct {
+		Begin  time.Time
+		OnTime time.Time
+		End    time.Time
+	}
+)
+
+// ValidateTemplates return error if a template of `templates` is of unknown
+// kind, can't be parsed, or can't be executed with example variables
+func ValidateTemplates(templates map[string]string) error {
+	var kinds []string
+	for kind := range templates {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	example := exampleTemplateData()
+	for _, kind := range kinds {
+		if !isTemplateKind(kind) {
+			var names []string
+			for _, k := range templateKinds {
+				names = append(names, string(k))
+			}
+			return fmt.Errorf("template %s is unknown, templates are %s", kind,
+				strings.Join(names, ", "))
+		}
+		if _, err := execute(kind, templates[kind], example); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exampleTemplateData return variables to validate templates
+func exampleTemplateData() templateData {
+	now := util.GetChinaTimeNow()
+	return templateData{User: "some_one", Users: []string{"some_one"}, Time: now,
+		streak: func() int { return 1 }, Window: templateWindow{now, now, now},
+		Kind: model.CheckInOffice, Status: model.CheckInOnTime}
+}
+
+func isTemplateKind(kind string) bool {
+	for _, k := range templateKinds {
+		if string(k) == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// templateFuncs is functions of templates, `{{mention .Users}}` mention
+// users in both private chats and channels
+var templateFuncs = template.FuncMap{"mention": mention}
+
+// mention return `users` mentioned like `@a, @b`
+func mention(users []string) string {
+	if len(users) == 0 {
+		return ""
+	}
+	return "@" + strings.Join(users, ", @")
+}
+
+func execute(kind, text string, data templateData) (string, error) {
+	t, err := template.New(kind).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parse template %s error %s", kind, err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("execute template %s error %s", kind, err)
+	}
+	return buf.String(), nil
+}
+
+// render return message of `kind` executed by the template configured for
+// it with `data`, or `fallback` if there isn't a template or it failed
+func render(r repo.Repo, l logging.Logger, kind i18n.Key, data templateData,
+	fallback string) string {
+	text, ok := r.Cfg().Templates[string(kind)]
+	if !ok {
+		return fallback
+	}
+	// templates were validated with example variables when bot started, but
+	// they may still fail with real ones
+	msg, err := execute(string(kind), text, data)
+	if err != nil {
+		l.Error("render template failed", logging.Err(err))
+		return fallback
+	}
+	return msg
+}
+
+// Streak return number of consecutive days checked in until Time, days off
+// are skipped
+func (d templateData) Streak() int {
+	if d.streak == nil {
+		return 0
+	}
+	return d.streak()
+}
+
+// newTemplateData return variables of templates about `user` at `t`, the
+// streak is counted by check ins of `r` when a template uses it, there isn't
+// a streak if `user` is empty
+func newTemplateData(r repo.Repo, user string, t time.Time) templateData {
+	t = util.GetChinaTimeFromUnix(t.Unix())
+	data := templateData{User: user, Time: t}
+	if user != "" {
+		data.Users = []string{user}
+		n := -1
+		data.streak = func() int {
+			if n < 0 {
+				n = streak(r, user, t)
+			}
+			return n
+		}
+	}
+	// the policy was validated when bot started, so error is ignored
+	w, _ := newCheckInWindow(r.Cfg(), t)
+	data.Window = templateWindow{w.begin, w.onTime, w.end}
+	return data
+}
+
+// streak return number of consecutive days on which `user` checked in
+// until the day of `t`, a day without check in isn't a break if reminders
+// weren't due on it by the calendar, or it's the day of `t`
+func streak(r repo.Repo, user string, t time.Time) int {
+	cal := calendarOf(r.Cfg().Group)
+	n := 0
+	for i, day := 0, t; i < streakDays; i, day = i+1, day.AddDate(0, 0, -1) {
+		if _, err := r.Get(repo.CheckInID(user, day)); err == nil {
+			n++
+			continue
+		}
+		if i == 0 || !cal.isWorkDay(day) {
+			continue
+		}
+		break
+	}
+	return n
+}
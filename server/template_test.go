@@ -0,0 +1,139 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zhao-kun/reminder-tgbot/i18n"
+	"github.com/zhao-kun/reminder-tgbot/model"
+	"github.com/zhao-kun/reminder-tgbot/repo"
+	"github.com/zhao-kun/reminder-tgbot/util"
+)
+
+// countingRepo count how many check ins are read
+type countingRepo struct {
+	repo.Repo
+	gets int
+}
+
+func (r *countingRepo) Get(id string) (model.CheckIn, error) {
+	r.gets++
+	return r.Repo.Get(id)
+}
+
+// chinaDay return 10:00 of the day in Asia/Shanghai
+func chinaDay(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 10, 0, 0, 0, util.GetChinaTimeFromUnix(0).Location())
+}
+
+func TestStreak(t *testing.T) {
+	// 2021-03-03 is a Wednesday
+	wed := chinaDay(2021, 3, 3)
+	tue, mon := wed.AddDate(0, 0, -1), wed.AddDate(0, 0, -2)
+	sat, fri := wed.AddDate(0, 0, -4), wed.AddDate(0, 0, -5)
+	var everyDay []time.Time
+	for i := 0; i < 100; i++ {
+		everyDay = append(everyDay, wed.AddDate(0, 0, -i))
+	}
+	tests := []struct {
+		name     string
+		checkIns []time.Time
+		// workDays override the calendar of days
+		workDays map[time.Time]bool
+		want     int
+	}{
+		{name: "no check in"},
+		{name: "consecutive days", checkIns: []time.Time{mon, tue, wed}, want: 3},
+		{name: "today isn't a break", checkIns: []time.Time{mon, tue}, want: 2},
+		{name: "weekend is skipped", checkIns: []time.Time{fri, mon, tue, wed}, want: 4},
+		{name: "a missing work day is a break", checkIns: []time.Time{fri, mon, wed}, want: 1},
+		{
+			name:     "holiday is skipped",
+			checkIns: []time.Time{mon, wed},
+			workDays: map[time.Time]bool{tue: false},
+			want:     2,
+		},
+		{
+			name:     "a missing make up work day is a break",
+			checkIns: []time.Time{fri, mon, tue, wed},
+			workDays: map[time.Time]bool{sat: true},
+			want:     3,
+		},
+		{name: "days are limited", checkIns: everyDay, want: streakDays},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := model.Config{Group: "streak " + tt.name}
+			r, cleanup := testRepo(t, cfg)
+			defer cleanup()
+			for _, day := range tt.checkIns {
+				if err := r.Record(model.CheckIn{User: "alice", Time: day}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cal := calendarOf(cfg.Group)
+			for day, workDay := range tt.workDays {
+				cal.update(day, workDay)
+			}
+			if got := streak(r, "alice", wed); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	fallback := "OK! you are checked in @alice"
+	tests := []struct {
+		name      string
+		templates map[string]string
+		want      string
+		// counted is whether the streak is counted
+		counted bool
+	}{
+		{name: "no template", want: fallback},
+		{
+			name:      "template of another kind",
+			templates: map[string]string{string(i18n.Reminder): "{{.Streak}}"},
+			want:      fallback,
+		},
+		{
+			name:      "template without streak",
+			templates: map[string]string{string(i18n.CheckedIn): "Got it {{mention .Users}}"},
+			want:      "Got it @alice",
+		},
+		{
+			name:      "template with streak",
+			templates: map[string]string{string(i18n.CheckedIn): "{{.User}} {{.Streak}} {{.Streak}}"},
+			want:      "alice 1 1",
+			counted:   true,
+		},
+		{
+			name:      "failed template",
+			templates: map[string]string{string(i18n.CheckedIn): "{{index .Users 5}}"},
+			want:      fallback,
+		},
+	}
+	now := chinaDay(2021, 3, 3)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, cleanup := testRepo(t, model.Config{Templates: tt.templates})
+			defer cleanup()
+			if err := base.Record(model.CheckIn{User: "alice", Time: now}); err != nil {
+				t.Fatal(err)
+			}
+			r := &countingRepo{Repo: base}
+			data := newTemplateData(r, "alice", now)
+			if got := render(r, testLogger(t), i18n.CheckedIn, data, fallback); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if counted := r.gets > 0; counted != tt.counted {
+				t.Errorf("streak is counted %v, want %v", counted, tt.counted)
+			}
+			gets := r.gets
+			if data.Streak(); tt.counted && r.gets != gets {
+				t.Errorf("streak is counted again, %d check ins are read", r.gets-gets)
+			}
+		})
+	}
+}
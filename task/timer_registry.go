@@ -2,9 +2,12 @@ package task
 
 import (
 	"fmt"
-	"log"
+	"sort"
 	"sync"
 	"time"
+
+	"github.com/zhao-kun/reminder-tgbot/logging"
+	"github.com/zhao-kun/reminder-tgbot/metrics"
 )
 
 const (
@@ -27,16 +30,46 @@ type (
 		stop chan struct{}
 
 		status int
+
+		startedAt    time.Time
+		lastRunAt    time.Time
+		lastDuration time.Duration
+		lastResult   bool
+		runs         int
 	}
 	taskRegistry struct {
 		sync.Mutex
 		tasks map[string]*task
+		l     logging.Logger
 	}
 )
 
 var _ Registry = &taskRegistry{}
 var _ Task = &task{}
 
+func (r *taskRegistry) Tasks() []Status {
+	r.Lock()
+	defer r.Unlock()
+	status := make([]Status, 0, len(r.tasks))
+	for _, t := range r.tasks {
+		status = append(status, t.Status())
+	}
+	sort.Slice(status, func(i, j int) bool {
+		return status[i].Name < status[j].Name
+	})
+	return status
+}
+
+func (r *taskRegistry) Task(name string) (Task, bool) {
+	r.Lock()
+	defer r.Unlock()
+	t, ok := r.tasks[name]
+	if !ok {
+		return nil, false
+	}
+	return t, true
+}
+
 func (r *taskRegistry) StartAllTask() {
 	for k := range r.tasks {
 		r.runTask(r.tasks[k])
@@ -66,6 +99,7 @@ func (r *taskRegistry) runTask(t *task) {
 	}
 
 	t.status = taskStatusRuning
+	t.startedAt = time.Now()
 	go func() {
 		ticker := time.NewTicker(t.interval)
 		setTaskStop := func() {
@@ -76,14 +110,18 @@ func (r *taskRegistry) runTask(t *task) {
 		for t.status == taskStatusRuning {
 			select {
 			case <-ticker.C:
-				if t.callback() == false {
+				r.l.Debug("task is running", logging.Task(t.name))
+				begin := time.Now()
+				next := t.callback()
+				t.recordRun(begin, time.Since(begin), next)
+				if next == false {
 					setTaskStop()
 				}
 			case <-t.stop:
 				setTaskStop()
 			}
 		}
-		log.Printf("Task %s was exited", t.name)
+		r.l.Info("task was exited", logging.Task(t.name))
 	}()
 }
 
@@ -91,6 +129,55 @@ func (t *task) Name() string {
 	return t.name
 }
 
+func (t *task) Status() Status {
+	t.Lock()
+	defer t.Unlock()
+	s := Status{
+		Name:      t.name,
+		Interval:  t.interval.String(),
+		Status:    "stopped",
+		StartedAt: t.startedAt,
+		LastRunAt: t.lastRunAt,
+		Runs:      t.runs,
+	}
+	if t.status == taskStatusRuning {
+		s.Status = "running"
+	}
+	if t.runs > 0 {
+		s.LastDuration = t.lastDuration.String()
+		s.LastResult = "stop"
+		if t.lastResult {
+			s.LastResult = "continue"
+		}
+	}
+	return s
+}
+
+// Alive return false if the task was stopped or it hasn't run for more than
+// 3 intervals, e.g. the callback hung
+func (t *task) Alive(now time.Time) bool {
+	t.Lock()
+	defer t.Unlock()
+	if t.status != taskStatusRuning {
+		return false
+	}
+	last := t.lastRunAt
+	if last.IsZero() {
+		last = t.startedAt
+	}
+	return now.Sub(last) <= 3*t.interval
+}
+
+func (t *task) recordRun(begin time.Time, duration time.Duration, result bool) {
+	metrics.TaskRunDuration.Observe(duration.Seconds(), t.name)
+	t.Lock()
+	defer t.Unlock()
+	t.lastRunAt = begin
+	t.lastDuration = duration
+	t.lastResult = result
+	t.runs++
+}
+
 // New return a Task interface
 func New(name string, duration string, taskFunc CallbackFunc) (Task, error) {
 	d, err := time.ParseDuration(duration)
@@ -105,10 +192,11 @@ func New(name string, duration string, taskFunc CallbackFunc) (Task, error) {
 	}, nil
 }
 
-// NewTaskRegistry return a TaskRegistry
-func NewTaskRegistry() Registry {
+// NewTaskRegistry return a TaskRegistry which log by `l`
+func NewTaskRegistry(l logging.Logger) Registry {
 	return &taskRegistry{
 		tasks: make(map[string]*task, 10),
+		l:     l,
 	}
 }
 
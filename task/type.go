@@ -1,6 +1,23 @@
 package task
 
+import "time"
+
 type (
+	// Status represent current status of a task
+	Status struct {
+		Name     string `json:"name"`
+		Interval string `json:"interval"`
+		// Status is running or stopped
+		Status    string    `json:"status"`
+		StartedAt time.Time `json:"started_at"`
+		LastRunAt time.Time `json:"last_run_at"`
+		// LastDuration is how long the last run took
+		LastDuration string `json:"last_duration,omitempty"`
+		// LastResult is continue or stop, which was returned by the last run
+		LastResult string `json:"last_result,omitempty"`
+		Runs       int    `json:"runs"`
+	}
+
 	// Context is context will passed between task callback func
 	Context map[string]interface{}
 
@@ -11,9 +28,16 @@ type (
 	Registry interface {
 		StartAllTask()
 		AddTask(Task) error
+		// Tasks return status of all registered tasks ordered by name
+		Tasks() []Status
+		// Task return the registered task named `name`
+		Task(name string) (Task, bool)
 	}
 	// Task represent a task
 	Task interface {
 		Name() string
+		Status() Status
+		// Alive return whether the task is still running periodically
+		Alive(now time.Time) bool
 	}
 )
@@ -1,59 +1,239 @@
 package telegram
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"mime/multipart"
+	"strconv"
+	"strings"
+	"time"
 
 	httpclient "github.com/zhao-kun/reminder-tgbot/client"
+	"github.com/zhao-kun/reminder-tgbot/logging"
+	"github.com/zhao-kun/reminder-tgbot/metrics"
 	"github.com/zhao-kun/reminder-tgbot/model"
 )
 
+// ErrNotModified is returned by Edit if the text and buttons of the message
+// are the same as the new ones
+var ErrNotModified = fmt.Errorf("message is not modified")
+
 type (
 	// Client represent a telegram client which send requst to specific
 	// group or channel
 	Client interface {
-		Reply(message model.ReplyMessage) error
-		Message(message model.BotMessage) error
+		Reply(ctx context.Context, message model.ReplyMessage) error
+		Message(ctx context.Context, message model.BotMessage) error
+		// Post send a message like Message and return id of the message
+		Post(ctx context.Context, message model.BotMessage) (int, error)
+		// Sticker send a sticker to chat
+		Sticker(ctx context.Context, message model.StickerMessage) error
+		// Animation send a GIF to chat
+		Animation(ctx context.Context, message model.AnimationMessage) error
+		// Document upload a file to chat
+		Document(ctx context.Context, message model.DocumentMessage) error
+		// Edit replace text of a message sent by bot, ErrNotModified is
+		// returned if nothing is changed
+		Edit(ctx context.Context, message model.EditMessage) error
+		// AnswerCallback answer a callback query of inline keyboard
+		AnswerCallback(ctx context.Context, answer model.CallbackAnswer) error
+		// SetCommands set the command menu of bot for users of
+		// `languageCode`, it's for all users if `languageCode` is empty
+		SetCommands(ctx context.Context, commands []model.BotCommand, languageCode string) error
+		// GetMe return the user of bot itself
+		GetMe(ctx context.Context) (model.From, error)
+	}
+
+	// response is the envelope of telegram bot API responses
+	response struct {
+		OK          bool            `json:"ok"`
+		Result      json.RawMessage `json:"result"`
+		Description string          `json:"description"`
 	}
 
 	client struct {
 		cfg model.Config
+		hc  httpclient.Client
+		l   logging.Logger
 	}
 )
 
 var _ Client = client{}
 
-func (c client) Reply(message model.ReplyMessage) error {
+func (c client) Reply(ctx context.Context, message model.ReplyMessage) error {
 	if message.ReplyToMessageID <= 0 {
 		return fmt.Errorf("Reply message should refer to a origin message")
 	}
-	return sendMessage(c.cfg, message)
+	return c.sendMessage(ctx, message)
 }
 
-func (c client) Message(message model.BotMessage) error {
-	return sendMessage(c.cfg, message)
+func (c client) Message(ctx context.Context, message model.BotMessage) error {
+	return c.sendMessage(ctx, message)
 }
 
-func sendMessage(cfg model.Config, message interface{}) error {
+func (c client) Post(ctx context.Context, message model.BotMessage) (int, error) {
+	if message.Text == "" {
+		return 0, fmt.Errorf("Message should contains text")
+	}
+	body, err := json.Marshal(message)
+	if err != nil {
+		return 0, fmt.Errorf("Marsh json of resp %+v error %s", message, err)
+	}
+	resp, err := c.call(ctx, "sendMessage", "application/json", body)
+	if err != nil {
+		return 0, err
+	}
+	var sent model.Message
+	if err := decode("sendMessage", resp, &sent); err != nil {
+		return 0, err
+	}
+	c.l.Debug("request was sent", logging.F("method", "sendMessage"),
+		logging.F("request", string(body)))
+	return sent.MessageID, nil
+}
+
+func (c client) sendMessage(ctx context.Context, message interface{}) error {
 	if text, ok := message.(model.Text); ok {
 		if text.TextInfo() == "" {
 			return fmt.Errorf("Message should contains text")
 		}
 	}
-	request, err := json.Marshal(message)
+	return c.callJSON(ctx, "sendMessage", message)
+}
+
+func (c client) Sticker(ctx context.Context, message model.StickerMessage) error {
+	if message.Sticker == "" {
+		return fmt.Errorf("Message should contains sticker")
+	}
+	return c.callJSON(ctx, "sendSticker", message)
+}
+
+func (c client) Animation(ctx context.Context, message model.AnimationMessage) error {
+	if message.Animation == "" {
+		return fmt.Errorf("Message should contains animation")
+	}
+	return c.callJSON(ctx, "sendAnimation", message)
+}
+
+func (c client) Edit(ctx context.Context, message model.EditMessage) error {
+	if message.Text == "" {
+		return fmt.Errorf("Message should contains text")
+	}
+	err := c.callJSON(ctx, "editMessageText", message)
+	if err != nil && strings.Contains(err.Error(), ErrNotModified.Error()) {
+		return ErrNotModified
+	}
+	return err
+}
+
+func (c client) AnswerCallback(ctx context.Context, answer model.CallbackAnswer) error {
+	return c.callJSON(ctx, "answerCallbackQuery", answer)
+}
+
+func (c client) SetCommands(ctx context.Context, commands []model.BotCommand,
+	languageCode string) error {
+	return c.callJSON(ctx, "setMyCommands", struct {
+		Commands     []model.BotCommand `json:"commands"`
+		LanguageCode string             `json:"language_code,omitempty"`
+	}{commands, languageCode})
+}
+
+func (c client) GetMe(ctx context.Context) (model.From, error) {
+	var me model.From
+	body, err := c.call(ctx, "getMe", "application/json", nil)
 	if err != nil {
-		return fmt.Errorf("Marsh json of resp %+v error %s", message, err)
+		return me, err
 	}
+	err = decode("getMe", body, &me)
+	return me, err
+}
 
-	_, err = httpclient.HandleRequest("POST",
-		fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", cfg.TgbotToken), request)
+// decode unmarshal result of `body` which is response of `method` to `v`
+func decode(method string, body []byte, v interface{}) error {
+	var resp response
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("unmarshal response of %s error %s", method, err)
+	}
+	if !resp.OK {
+		return fmt.Errorf("%s failed %s", method, resp.Description)
+	}
+	if err := json.Unmarshal(resp.Result, v); err != nil {
+		return fmt.Errorf("unmarshal result of %s error %s", method, err)
+	}
+	return nil
+}
+
+// callJSON request telegram bot API `method` with `request` as json body
+func (c client) callJSON(ctx context.Context, method string, request interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("Marsh json of resp %+v error %s", request, err)
+	}
+
+	_, err = c.call(ctx, method, "application/json", body)
 	if err != nil {
 		return err
 	}
+	c.l.Debug("request was sent", logging.F("method", method),
+		logging.F("request", string(body)))
 	return nil
 }
 
-// NewClient return a telegram Client object
-func NewClient(cfg model.Config) Client {
-	return client{cfg}
+func (c client) Document(ctx context.Context, message model.DocumentMessage) error {
+	if message.FileName == "" {
+		return fmt.Errorf("Document should have a file name")
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	fields := map[string]string{
+		"chat_id": strconv.FormatInt(message.ChatID, 10),
+		"caption": message.Caption,
+	}
+	if message.ReplyToMessageID > 0 {
+		fields["reply_to_message_id"] = strconv.Itoa(message.ReplyToMessageID)
+	}
+	for k, v := range fields {
+		if err := writer.WriteField(k, v); err != nil {
+			return err
+		}
+	}
+	part, err := writer.CreateFormFile("document", message.FileName)
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(message.Content); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	_, err = c.call(ctx, "sendDocument", writer.FormDataContentType(), body.Bytes())
+	if err != nil {
+		return err
+	}
+	c.l.Debug("document was sent", logging.ChatID(message.ChatID),
+		logging.F("file", message.FileName))
+	return nil
+}
+
+// call request telegram bot API `method` and return body of the response
+func (c client) call(ctx context.Context, method string, contentType string,
+	body []byte) ([]byte, error) {
+	begin := time.Now()
+	resp, err := c.hc.HandleRequestWithContentType(ctx, "POST",
+		fmt.Sprintf("https://api.telegram.org/bot%s/%s", c.cfg.TgbotToken, method),
+		contentType, body)
+	metrics.TelegramAPIDuration.Observe(time.Since(begin).Seconds(), method,
+		metrics.Result(err))
+	return resp, err
+}
+
+// NewClient return a telegram Client object which send request by `hc`
+// and log by `l`
+func NewClient(cfg model.Config, hc httpclient.Client, l logging.Logger) Client {
+	return client{cfg, hc, l}
 }
@@ -1,18 +1,25 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
 
 	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/spf13/cobra"
+	"github.com/zhao-kun/reminder-tgbot/client"
+	"github.com/zhao-kun/reminder-tgbot/i18n"
+	"github.com/zhao-kun/reminder-tgbot/importer"
+	"github.com/zhao-kun/reminder-tgbot/logging"
+	"github.com/zhao-kun/reminder-tgbot/metrics"
 	"github.com/zhao-kun/reminder-tgbot/model"
 	"github.com/zhao-kun/reminder-tgbot/repo"
 	"github.com/zhao-kun/reminder-tgbot/server"
+	"github.com/zhao-kun/reminder-tgbot/task"
 	"github.com/zhao-kun/reminder-tgbot/telegram"
 )
 
@@ -22,61 +29,122 @@ type (
 	}
 )
 
-// wrapClientRepo wrap a func with config parameter
-func wrapClientRepo(c telegram.Client, r repo.Repo,
-	f func(telegram.Client, repo.Repo, model.TgMessage)) rest.HandlerFunc {
+// dedupSize is how many ids of recent updates are remembered to drop
+// updates delivered again by Telegram
+const dedupSize = 1000
+
+// webhookHandle acknowledge updates sent by webhook immediately and push them
+// to `queue`, Telegram is asked to retry later if the queue is full
+func webhookHandle(queue server.UpdateQueue, l logging.Logger) rest.HandlerFunc {
 	return func(w rest.ResponseWriter, req *rest.Request) {
 		ok := func() {
 			w.WriteJson(response{true})
 			w.WriteHeader(http.StatusOK)
 		}
 
+		rl := l.With(logging.F("path", req.URL.Path))
 		body, err := ioutil.ReadAll(req.Body)
 		if err != nil {
+			rl.Error("read request body failed", logging.Err(err))
 			rest.Error(w, "read request body error", http.StatusBadGateway)
 			return
 		}
 
-		log.Printf("Request %s is comming body is:\n%s\n", req.URL.Path[1:], body)
+		rl.Debug("request is comming", logging.F("body", body))
 		var message model.TgMessage
 		err = json.Unmarshal(body, &message)
 		if err != nil {
-			log.Printf("Can't unmarshal body [%s] to message", body)
+			rl.Warn("can't unmarshal body to message", logging.Err(err))
 			ok()
 			return
 		}
-		f(c, r, message)
+		// updates are counted before queued, so dropped ones are counted too
+		metrics.UpdatesReceived.Inc()
+		if !queue.Push(message) {
+			metrics.UpdatesDropped.Inc("queue_full")
+			rl.Warn("update queue is full", logging.UpdateID(message.UpdateID))
+			rest.Error(w, "too many updates", http.StatusServiceUnavailable)
+			return
+		}
 		ok()
 	}
 
 }
-func startServer(c telegram.Client, r repo.Repo) (<-chan error, error) {
+func startServer(c telegram.Client, botName string, config model.Config, groups repo.Groups,
+	registry task.Registry, l logging.Logger) (<-chan error, error) {
 
-	checkInHandle := wrapClientRepo(c, r, server.TelegramServerHandle)
+	rateLimit, err := server.RateLimit(config.RateLimit)
+	if err != nil {
+		return nil, err
+	}
+	updates, err := repo.NewUpdates(config.DataDir, dedupSize)
+	if err != nil {
+		return nil, fmt.Errorf("load update ids error %s", err)
+	}
+	queue, err := server.NewUpdateQueue(config.WorkerPool, server.Chain(
+		server.TelegramServerHandle(botName),
+		server.Recover(),
+		server.Measure(),
+		server.Log(),
+		server.Dedup(updates),
+		server.Authorize(),
+		rateLimit,
+	), c, groups, l)
+	if err != nil {
+		return nil, err
+	}
+	checkInHandle := webhookHandle(queue, l)
+	tokens := config.API.Tokens
+	byGroup := func(h func(repo.Repo, logging.Logger) rest.HandlerFunc) rest.HandlerFunc {
+		return server.RequireToken(tokens, server.ByGroup(groups,
+			func(r repo.Repo) rest.HandlerFunc { return h(r, l) }))
+	}
 	router, err := rest.MakeRouter(
-		rest.Post(r.Cfg().WebhookEndpoint, checkInHandle),
-		rest.Put(r.Cfg().WebhookEndpoint, checkInHandle),
+		rest.Post(config.WebhookEndpoint, checkInHandle),
+		rest.Put(config.WebhookEndpoint, checkInHandle),
+		rest.Get("/healthz", server.HealthzHandle),
+		rest.Get("/readyz", server.ReadyzHandle(groups, registry, config.CalendarOptional)),
+		rest.Get("/api/tasks", server.RequireToken(tokens, server.TasksHandle(registry))),
+		rest.Get("/api/checkins", byGroup(server.ListCheckInsHandle)),
+		rest.Post("/api/checkins", byGroup(server.CreateCheckInHandle)),
+		rest.Delete("/api/checkins/:id", byGroup(server.DeleteCheckInHandle)),
+		rest.Get("/api/users", byGroup(server.ListUsersHandle)),
+		rest.Get("/api/export", byGroup(server.ExportHandle)),
+		rest.Get("/dashboard", byGroup(server.DashboardHandle)),
 	)
 	if err != nil {
-		log.Fatalf("Make router error :%s", err)
+		l.Error("make router failed", logging.Err(err))
 		return nil, err
 	}
 
 	apiServer := rest.NewApi()
-	apiServer.Use(rest.DefaultCommonStack...)
+	apiServer.Use(
+		&rest.TimerMiddleware{},
+		&rest.RecorderMiddleware{},
+		&rest.PoweredByMiddleware{},
+		&rest.RecoverMiddleware{
+			Logger: log.New(logging.NewWriter(l, logging.LevelError), "", 0),
+		},
+	)
 	apiServer.SetApp(router)
 
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.Handle("/", apiServer.MakeHandler())
+
 	done := make(chan error, 1)
 	go func() {
 		server := &http.Server{
-			Addr:    r.Cfg().ListenAddr,
-			Handler: apiServer.MakeHandler(),
+			Addr:     config.ListenAddr,
+			Handler:  mux,
+			ErrorLog: log.New(logging.NewWriter(l, logging.LevelWarn), "", 0),
 		}
 
-		log.Printf("Start Listen on: %s", r.Cfg().ListenAddr)
+		l.Info("start listening", logging.F("addr", config.ListenAddr))
 		err := server.ListenAndServe()
 		if err != nil {
-			log.Printf("ListenAndServe on %s error:%s", r.Cfg().ListenAddr, err)
+			l.Error("listen and serve failed", logging.F("addr", config.ListenAddr),
+				logging.Err(err))
 			done <- err
 		}
 	}()
@@ -85,7 +153,6 @@ func startServer(c telegram.Client, r repo.Repo) (<-chan error, error) {
 
 func readConf(path string) (cfg model.Config, err error) {
 	cfg.ListenAddr = ":8888"
-	cfg.CheckUesrs = []string{"zhaokun"}
 	// a chinese festival calendar service maybe broken in feature
 	cfg.CNCalendarServiceEndpoint = "http://api.goseek.cn/Tools/holiday"
 	file, err := os.OpenFile(path, os.O_RDONLY, 0600)
@@ -98,9 +165,11 @@ func readConf(path string) (cfg model.Config, err error) {
 		return cfg, fmt.Errorf("read file contents error %s", err)
 	}
 
+	// contents of configuration file contain the bot token, so it isn't a
+	// part of error
 	err = json.Unmarshal(c, &cfg)
 	if err != nil {
-		return cfg, fmt.Errorf("unmarshal [%s] error", string(c))
+		return cfg, fmt.Errorf("unmarshal %s error %s", path, err)
 	}
 
 	if cfg.WebhookEndpoint == "" {
@@ -110,33 +179,157 @@ func readConf(path string) (cfg model.Config, err error) {
 	return
 }
 
-func main() {
-	bdir, _ := filepath.Abs(filepath.Dir(os.Args[0]))
-	config, err := readConf(fmt.Sprintf("%s/config.json", bdir))
+// loadConf read configuration from the file located by `opts` and create
+// the logger, DataDir of the configuration is resolved as well
+func loadConf(opts dirOptions) (model.Config, logging.Logger, error) {
+	configPath := resolveConfigPath(opts)
+	config, err := readConf(configPath)
 	if err != nil {
-		log.Fatalf("readConf error %s", err)
+		return config, nil, fmt.Errorf("readConf error %s", err)
 	}
+	config.DataDir = resolveDataDir(opts, config.DataDir)
 
-	r := repo.New(config)
-	c := telegram.NewClient(config)
+	l, err := logging.New(config.Log, os.Stderr, config.TgbotToken)
+	if err != nil {
+		return config, nil, fmt.Errorf("create logger error %s", err)
+	}
+	logging.RedirectStdLog(l)
+	return config, l, nil
+}
 
-	err = server.StartAllBotTask(c, r)
+func serve(opts dirOptions) {
+	config, l, err := loadConf(opts)
 	if err != nil {
 		log.Fatalf("%s", err)
 	}
+	if err := i18n.Validate(config.Messages); err != nil {
+		fatal(l, "messages are invalid", err)
+	}
+	if err := i18n.ValidateLanguage(config.Language); err != nil {
+		fatal(l, "language is invalid", err)
+	}
+	if err := server.ValidateTemplates(config.Templates); err != nil {
+		fatal(l, "templates are invalid", err)
+	}
+	for _, g := range config.Groups {
+		if err := server.ValidateTemplates(g.Templates); err != nil {
+			fatal(l, "templates are invalid", fmt.Errorf("group %s: %s", g.Name, err))
+		}
+		if err := i18n.ValidateLanguage(g.Language); err != nil {
+			fatal(l, "language is invalid", fmt.Errorf("group %s: %s", g.Name, err))
+		}
+	}
+
+	hc, err := client.New(config.HTTPClient, l)
+	if err != nil {
+		fatal(l, "create http client failed", err)
+	}
 
-	done, err := startServer(c, r)
+	groups, err := repo.NewGroups(config, l)
 	if err != nil {
-		log.Fatalf("boot server error")
+		fatal(l, "open repo failed", err)
+	}
+	// ctx is done when the server stopped, requests of the bot are canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := telegram.NewClient(config, hc, l)
+	me, err := c.GetMe(ctx)
+	if err != nil {
+		l.Warn("get username of bot failed, commands addressed to other bots aren't ignored",
+			logging.Err(err))
+	}
+	if err := server.RegisterCommands(ctx, c, config.Messages); err != nil {
+		l.Warn("set command menu failed", logging.Err(err))
+	}
+
+	// early versions stored check ins where the default group does
+	if r, ok := groups.Get(model.DefaultGroup); ok {
+		pending, err := importer.Pending(r, r.CheckInDir(), l)
+		if err != nil {
+			l.Warn("look up legacy check ins failed", logging.Err(err))
+		}
+		if pending > 0 {
+			// legacy check ins are invisible until they're imported
+			result, err := importer.Import(r, r.CheckInDir(), l, importer.Options{})
+			if err != nil {
+				fatal(l, "import legacy check ins failed", err)
+			}
+			l.Info("legacy check ins were imported", logging.F("imported", result.Imported),
+				logging.F("skipped", result.Skipped), logging.F("failed", len(result.Failures)))
+			if len(result.Failures) > 0 {
+				l.Warn("some legacy check ins can't be imported, run `tgbot import --dry-run` "+
+					"to see them", logging.F("failed", len(result.Failures)))
+			}
+		}
+	}
+
+	registry, err := server.StartAllBotTask(ctx, c, hc, groups, l)
+	if err != nil {
+		fatal(l, "start bot task failed", err)
+	}
+
+	done, err := startServer(c, me.Username, config, groups, registry, l)
+	if err != nil {
+		fatal(l, "boot server failed", err)
 		return
 	}
 
 	for {
 		select {
 		case err = <-done:
+			cancel()
 			if err != nil {
-				log.Fatalf("start server error %s, exit", err)
+				fatal(l, "start server failed, exit", err)
 			}
 		}
 	}
 }
+
+// openGroup return Repo of group `name`, it's the first group if `name` is
+// empty
+func openGroup(config model.Config, l logging.Logger, name string) (repo.Repo, error) {
+	groups, err := repo.NewGroups(config, l)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return groups.All()[0], nil
+	}
+	r, ok := groups.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("group %s not found", name)
+	}
+	return r, nil
+}
+
+func fatal(l logging.Logger, msg string, err error) {
+	l.Error(msg, logging.Err(err))
+	os.Exit(1)
+}
+
+func main() {
+	var opts dirOptions
+	rootCmd := &cobra.Command{
+		Use:   "tgbot",
+		Short: "A telegram bot which reminds members of group to check in",
+		Args:  cobra.NoArgs,
+		// usage isn't helpful when a command failed at runtime
+		SilenceUsage: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			serve(opts)
+		},
+	}
+	flags := rootCmd.PersistentFlags()
+	flags.StringVar(&opts.config, "config", "",
+		"path of config.json, it can be set by "+envConfig+" as well")
+	flags.StringVar(&opts.configDir, "config-dir", "",
+		"directory of config.json, it can be set by "+envConfigDir+" as well")
+	flags.StringVar(&opts.dataDir, "data-dir", "",
+		"directory where check in history is stored, it can be set by "+envDataDir+" as well")
+	rootCmd.AddCommand(newExportCommand(&opts))
+	rootCmd.AddCommand(newImportCommand(&opts))
+
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
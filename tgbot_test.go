@@ -0,0 +1,76 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/zhao-kun/reminder-tgbot/logging"
+	"github.com/zhao-kun/reminder-tgbot/metrics"
+	"github.com/zhao-kun/reminder-tgbot/model"
+)
+
+// fakeQueue accept updates until it's full
+type fakeQueue struct {
+	full    bool
+	updates []model.TgMessage
+}
+
+func (q *fakeQueue) Push(update model.TgMessage) bool {
+	if q.full {
+		return false
+	}
+	q.updates = append(q.updates, update)
+	return true
+}
+
+func TestWebhookHandle(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		full     bool
+		status   int
+		received float64
+		dropped  float64
+	}{
+		{name: "queued", body: `{"update_id": 1}`, status: http.StatusOK, received: 1},
+		{name: "queue is full", body: `{"update_id": 2}`, full: true,
+			status: http.StatusServiceUnavailable, received: 1, dropped: 1},
+		{name: "malformed", body: `{"update_id":`, status: http.StatusOK},
+	}
+	l, err := logging.New(model.Log{}, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &fakeQueue{full: tt.full}
+			api := rest.NewApi()
+			router, err := rest.MakeRouter(rest.Post("/webhook", webhookHandle(q, l)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			api.SetApp(router)
+			received := metrics.UpdatesReceived.Value()
+			dropped := metrics.UpdatesDropped.Value("queue_full")
+
+			req := httptest.NewRequest("POST", "/webhook", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			api.MakeHandler().ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("status is %d, want %d", w.Code, tt.status)
+			}
+			if got := metrics.UpdatesReceived.Value() - received; got != tt.received {
+				t.Errorf("%v updates are received, want %v", got, tt.received)
+			}
+			if got := metrics.UpdatesDropped.Value("queue_full") - dropped; got != tt.dropped {
+				t.Errorf("%v updates are dropped, want %v", got, tt.dropped)
+			}
+		})
+	}
+}
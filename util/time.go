@@ -29,6 +29,12 @@ func GetChinaTimeNow() time.Time {
 	return t.In(chinaTime)
 }
 
+// ParseChinaDate parse "yyyy-mm-dd" string to the begin of the day in
+// `Asia/Shanghai`
+func ParseChinaDate(date string) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02", date, chinaTime)
+}
+
 // GetDate return "yyyymmdd" string to represent date
 func GetDate(date time.Time) string {
 	y, m, d := date.Date()